@@ -0,0 +1,221 @@
+package binpack
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Annotate writes a hex dump of data to w that explains every byte: each
+// line holds the offset, one byte or a run of payload bytes, and what they
+// mean. Nested values are indented and every Closure names the List or Dict
+// it ends, for example:
+//
+//	00000000  02                       List
+//	00000001  80                         length continuation, 7 bits = 0 << 0 (NumSignBit set, NumMask bits)
+//	00000002  21                         String len=128 (MaskLastUintLen bits = 1 << 7)
+//	00000003  61 62 63 64 65 66 67 68      String data "abcdefgh"
+//	...
+//	00000083  01                       Closure (end List @0x0)
+//
+// Malformed bytes are pointed out and the dump goes on with the next byte,
+// so Annotate only returns errors from writing to w.
+func Annotate(data []byte, w io.Writer) error {
+	a := annotator{w: w, data: data}
+	for a.off < len(data) && a.err == nil {
+		a.value()
+	}
+	for i := len(a.stack) - 1; i >= 0 && a.err == nil; i-- {
+		f := a.stack[i]
+		a.line(len(data), 0, i, "missing Closure for %v @%#x", f.code, f.off)
+	}
+	return a.err
+}
+
+// annotator holds the state of Annotate.
+type annotator struct {
+	w     io.Writer
+	data  []byte
+	off   int
+	stack []annotateFrame
+	err   error
+}
+
+// annotateFrame is a List or Dict that has not been closed yet.
+type annotateFrame struct {
+	code Code
+	off  int
+	n    int // number of values seen inside
+}
+
+var intTypeNames = map[Code]string{
+	IntegerTypeByte:  "Byte",
+	IntegerTypeShort: "Short",
+	IntegerTypeInt:   "Int",
+	IntegerTypeLong:  "Long",
+}
+
+// line writes the n bytes at off, indented depth levels, with a description.
+func (a *annotator) line(off, n, depth int, format string, args ...interface{}) {
+	if a.err != nil {
+		return
+	}
+	b := make([]string, n)
+	for i, c := range a.data[off : off+n] {
+		b[i] = hex.EncodeToString([]byte{c})
+	}
+	_, a.err = fmt.Fprintf(a.w, "%08x  %-23s  %s%s\n", off, strings.Join(b, " "),
+		strings.Repeat("  ", depth), fmt.Sprintf(format, args...))
+}
+
+// value annotates the bytes of the value starting at a.off.
+func (a *annotator) value() {
+	depth := len(a.stack)
+	start := a.off
+	end := start
+	for end < len(a.data) && Code(a.data[end])&NumSignBit != 0 {
+		end++
+	}
+	if end == len(a.data) {
+		for i := start; i < end; i++ {
+			a.line(i, 1, depth, "continuation, 7 bits = %d (invalid: data ends before the type code)", a.data[i]&byte(NumMask))
+		}
+		a.off = end
+		return
+	}
+
+	c := Code(a.data[end])
+	var kind string
+	switch {
+	case c&Integer != 0:
+		kind = "value"
+	case c&MaskTypeStringOrBlob == String, c&MaskTypeStringOrBlob == Blob:
+		kind = "length"
+	}
+	if kind == "" {
+		for i := start; i < end; i++ {
+			a.line(i, 1, depth, "invalid continuation, 7 bits = %d (not followed by Integer, String or Blob)", a.data[i]&byte(NumMask))
+		}
+		a.off = end
+		a.single(c, depth)
+		return
+	}
+
+	var n uint64
+	overflow := false
+	var shift uint
+	for i := start; i < end; i++ {
+		bits := uint64(a.data[i] & byte(NumMask))
+		overflow = overflow || !addBits(&n, bits, shift)
+		a.line(i, 1, depth, "%s continuation, 7 bits = %d << %d (NumSignBit set, NumMask bits)", kind, bits, shift)
+		shift += 7
+	}
+	a.off = end + 1
+	a.count()
+
+	var bits Code
+	if c&Integer != 0 {
+		bits = c & maskLastIntegerBits
+	} else {
+		bits = c & MaskLastUintLen
+	}
+	overflow = overflow || !addBits(&n, uint64(bits), shift)
+	if overflow {
+		a.line(end, 1, depth, "%v with a number overflowing 64 bits (invalid)", c)
+		return
+	}
+
+	if kind == "length" {
+		a.line(end, 1, depth, "%v len=%d (MaskLastUintLen bits = %d << %d)", c, n, bits, shift)
+		a.payload(c, n, depth+1)
+		return
+	}
+	sign := ""
+	signBit := "clear"
+	if c&MaskIntegerSign != 0 {
+		sign, signBit = "-", "set"
+	}
+	itype := c & MaskIntegerType
+	a.line(end, 1, depth, "Integer %s %s%d (MaskIntegerSign %s, IntegerType%s, last 3 bits = %d << %d)",
+		intTypeNames[itype], sign, n, signBit, intTypeNames[itype], bits, shift)
+}
+
+// single annotates a value whose header is the single byte c at a.off.
+func (a *annotator) single(c Code, depth int) {
+	off := a.off
+	a.off++
+	switch c {
+	case List, Dict:
+		a.count()
+		a.line(off, 1, depth, "%v", c)
+		a.stack = append(a.stack, annotateFrame{code: c, off: off})
+	case Closure:
+		if depth == 0 {
+			a.line(off, 1, depth, "Closure (invalid: no List or Dict to end)")
+			return
+		}
+		f := a.stack[depth-1]
+		a.stack = a.stack[:depth-1]
+		if f.code == Dict && f.n%2 != 0 {
+			a.line(off, 1, depth-1, "Closure (end Dict @%#x, invalid: key without a value)", f.off)
+			return
+		}
+		a.line(off, 1, depth-1, "Closure (end %v @%#x)", f.code, f.off)
+	case True, False, Nil:
+		a.count()
+		a.line(off, 1, depth, "%v", c)
+	case Float, Double:
+		a.count()
+		a.line(off, 1, depth, "%v", c)
+		a.payload(c, header{code: c}.payload(), depth+1)
+	default:
+		if c&MaskTypeStringOrBlob == MaskTypeStringOrBlob {
+			a.line(off, 1, depth, "invalid code (String and Blob bits both set)")
+		} else {
+			a.line(off, 1, depth, "invalid code (reserved)")
+		}
+	}
+}
+
+// count records that a value starts in the innermost List or Dict.
+func (a *annotator) count() {
+	if len(a.stack) > 0 {
+		a.stack[len(a.stack)-1].n++
+	}
+}
+
+// payload annotates the n bytes following the header of a value of type c,
+// eight bytes per line.
+func (a *annotator) payload(c Code, n uint64, depth int) {
+	avail := uint64(len(a.data) - a.off)
+	if n > avail {
+		defer a.line(len(a.data), 0, depth, "%v data truncated: %d of %d bytes", c, avail, n)
+		n = avail
+	}
+	p := a.data[a.off : a.off+int(n)]
+	switch {
+	case c == Float && n == 4:
+		f := math.Float32frombits(binary.LittleEndian.Uint32(p))
+		a.line(a.off, 4, depth, "Float %s (4 bytes little-endian)", formatFloat(float64(f), 32))
+	case c == Double && n == 8:
+		f := math.Float64frombits(binary.LittleEndian.Uint64(p))
+		a.line(a.off, 8, depth, "Double %s (8 bytes little-endian)", formatFloat(f, 64))
+	default:
+		for i := 0; i < len(p); i += 8 {
+			j := i + 8
+			if j > len(p) {
+				j = len(p)
+			}
+			desc := ""
+			if c&MaskTypeStringOrBlob == String {
+				desc = " " + strconv.Quote(string(p[i:j]))
+			}
+			a.line(a.off+i, j-i, depth, "%v data%s", c, desc)
+		}
+	}
+	a.off += int(n)
+}
@@ -0,0 +1,64 @@
+package binpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	data, _ := Marshal([]interface{}{"hello", -1000, int8(5), float32(2)})
+	var b bytes.Buffer
+	if err := Annotate(data, &b); err != nil {
+		t.Fatalf("binpack:Annotate error %v", err)
+	}
+	want := `00000000  02                       List
+00000001  25                         String len=5 (MaskLastUintLen bits = 5 << 0)
+00000002  68 65 6c 6c 6f               String data "hello"
+00000007  e8                         value continuation, 7 bits = 104 << 0 (NumSignBit set, NumMask bits)
+00000008  67                         Integer Long -1000 (MaskIntegerSign set, IntegerTypeLong, last 3 bits = 7 << 7)
+00000009  4d                         Integer Byte 5 (MaskIntegerSign clear, IntegerTypeByte, last 3 bits = 5 << 0)
+0000000a  07                         Float
+0000000b  00 00 00 40                  Float 2 (4 bytes little-endian)
+0000000f  01                       Closure (end List @0x0)
+`
+	if b.String() != want {
+		t.Fatalf("binpack:Annotate got\n%s\nwanted\n%s", b.String(), want)
+	}
+}
+
+func TestAnnotate_Malformed(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want []string
+	}{
+		{"30", []string{"invalid code (String and Blob bits both set)"}},
+		{"0a", []string{"invalid code (reserved)"}},
+		{"8004", []string{"invalid continuation", "True"}},
+		{"8080", []string{"invalid: data ends before the type code"}},
+		{"01", []string{"Closure (invalid: no List or Dict to end)"}},
+		{"032561", []string{"String data truncated: 1 of 5 bytes", "missing Closure for Dict @0x0"}},
+		{"032161", []string{"missing Closure for Dict @0x0"}},
+		{"0321610f4101", []string{"Closure (end Dict @0x0, invalid: key without a value)"}},
+		{"ffffffffffffffffff7f41", []string{"overflowing 64 bits", "Integer Long 1 "}},
+	}
+	for _, test := range testCases {
+		data, _ := hex.DecodeString(test.in)
+		var b bytes.Buffer
+		if err := Annotate(data, &b); err != nil {
+			t.Fatalf("binpack:Annotate(%s) error %v", test.in, err)
+		}
+		for _, w := range test.want {
+			if !strings.Contains(b.String(), w) {
+				t.Fatalf("binpack:Annotate(%s) got\n%s\nwanted it to contain %q", test.in, b.String(), w)
+			}
+		}
+	}
+}
+
+func TestAnnotate_WriteError(t *testing.T) {
+	if err := Annotate([]byte{0x02, 0x01}, errorWriter{}); err == nil || err.Error() != "forced error" {
+		t.Fatalf("binpack:Annotate got error %v; wanted forced error", err)
+	}
+}
@@ -0,0 +1,455 @@
+// Package cbor transcodes between CBOR (RFC 8949) and binpack.
+//
+// Values are converted type for type: unsigned and negative integers to
+// Integers, byte strings to Blobs, text strings to Strings, arrays to
+// Lists, maps to Dicts, false, true and null to False, True and Nil, half
+// and single precision floats to Floats and double precision floats to
+// Doubles. The undefined simple value also becomes Nil. Indefinite-length
+// strings are joined, and indefinite-length arrays and maps map directly
+// onto Closure-terminated Lists and Dicts.
+//
+// Integers keep their width where binpack can express it: integers with a
+// 1, 2 or 4 byte argument become Byte, Short and Int Integers, and the
+// others Long Integers. In the other direction Long Integers are written
+// with the argument in the initial byte when they fit, and with an 8 byte
+// argument otherwise, so that binpack data survives a round trip through
+// CBOR, except for the sign of a negative zero. Lists and Dicts are always
+// written with indefinite lengths, which lets them stream.
+//
+// Tags have no binpack counterpart. Options.Tags selects whether they are
+// rejected, which is the default, or dropped while the tagged value is
+// kept. Other simple values are errors.
+package cbor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+
+	"github.com/theodesp/binpack"
+)
+
+// TagPolicy selects what ToBinpack does with CBOR tags.
+type TagPolicy int
+
+const (
+	// RejectTags makes tagged values an error.
+	RejectTags TagPolicy = iota
+	// DropTags drops tags, converting the tagged value as if it was not
+	// tagged.
+	DropTags
+)
+
+// Options configures the transcoders. The zero value rejects tags.
+type Options struct {
+	Tags TagPolicy
+}
+
+// ToBinpack reads a stream of CBOR values from r and writes their binpack
+// encodings to w, using the default Options.
+func ToBinpack(r io.Reader, w io.Writer) error {
+	return Options{}.ToBinpack(r, w)
+}
+
+// FromBinpack reads a stream of binpack values from r and writes them to w
+// as CBOR, using the default Options.
+func FromBinpack(r io.Reader, w io.Writer) error {
+	return Options{}.FromBinpack(r, w)
+}
+
+// ToBinpack reads a stream of CBOR values from r and writes their binpack
+// encodings to w. Arrays and maps are streamed, so memory use is bounded by
+// the largest string.
+func (o Options) ToBinpack(r io.Reader, w io.Writer) error {
+	rd := reader{r: bufio.NewReader(r), w: bufio.NewWriter(w), opts: o}
+	for {
+		if _, err := rd.r.Peek(1); err == io.EOF {
+			break
+		}
+		if err := rd.value(); err != nil {
+			return err
+		}
+	}
+	return rd.w.Flush()
+}
+
+// FromBinpack reads a stream of binpack values from r and writes them to w
+// as CBOR. Each top-level value is read whole before it is converted.
+func (o Options) FromBinpack(r io.Reader, w io.Writer) error {
+	dec := binpack.NewDecoder(r)
+	cw := &writer{w: bufio.NewWriter(w)}
+	for {
+		var raw binpack.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = binpack.Walk(raw, cw)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return cw.w.Flush()
+}
+
+// CBOR major types.
+const (
+	majorUint = iota
+	majorNegInt
+	majorBytes
+	majorText
+	majorArray
+	majorMap
+	majorTag
+	majorSimple
+)
+
+// indefinite is the additional information of indefinite-length items, and
+// the break code ending them.
+const (
+	indefinite = 31
+	breakCode  = 0xff
+)
+
+// maxDepth is the deepest nesting of arrays, maps and tags that ToBinpack
+// converts, the same as binpack.Unmarshal decodes.
+const maxDepth = 10000
+
+// reader holds the state of ToBinpack.
+type reader struct {
+	r     *bufio.Reader
+	w     *bufio.Writer
+	opts  Options
+	off   int64 // number of bytes read
+	depth int   // number of arrays, maps and tags being converted
+}
+
+// head is the initial byte of a data item and its argument.
+type head struct {
+	off   int64 // offset of the initial byte
+	major byte
+	info  byte   // additional information
+	arg   uint64 // argument, or the bits of a float
+	size  int    // number of bytes of the argument
+}
+
+func (h head) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("cbor: "+format+" at offset %d", append(args, h.off)...)
+}
+
+// readN reads the next n bytes. A corrupt length fails with
+// io.ErrUnexpectedEOF at the end of the input instead of allocating n bytes.
+func (rd *reader) readN(n uint64) ([]byte, error) {
+	var b bytes.Buffer
+	m, err := io.CopyN(&b, rd.r, int64(n))
+	rd.off += m
+	if err == io.EOF || uint64(m) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	return b.Bytes(), err
+}
+
+// head reads the next initial byte and argument.
+func (rd *reader) head() (head, error) {
+	h := head{off: rd.off}
+	c, err := rd.r.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return h, err
+	}
+	rd.off++
+	h.major, h.info = c>>5, c&0x1f
+	switch {
+	case h.info < 24:
+		h.arg = uint64(h.info)
+	case h.info <= 27:
+		h.size = 1 << (h.info - 24)
+		b, err := rd.readN(uint64(h.size))
+		if err != nil {
+			return h, err
+		}
+		for _, c := range b {
+			h.arg = h.arg<<8 | uint64(c)
+		}
+	case h.info == indefinite:
+		switch h.major {
+		case majorUint, majorNegInt, majorTag:
+			return h, h.errorf("invalid indefinite length for major type %d", h.major)
+		}
+	default:
+		return h, h.errorf("reserved additional information %d", h.info)
+	}
+	return h, nil
+}
+
+// emit writes the binpack encoding of v.
+func (rd *reader) emit(v binpack.Value) error {
+	b, err := v.MarshalBinpack()
+	if err == nil {
+		_, err = rd.w.Write(b)
+	}
+	return err
+}
+
+// intTypes maps the size of an integer argument to a binpack subtype.
+var intTypes = map[int]binpack.Code{
+	0: binpack.IntegerTypeLong,
+	1: binpack.IntegerTypeByte,
+	2: binpack.IntegerTypeShort,
+	4: binpack.IntegerTypeInt,
+	8: binpack.IntegerTypeLong,
+}
+
+// value converts the next data item.
+func (rd *reader) value() error {
+	h, err := rd.head()
+	if err != nil {
+		return err
+	}
+	return rd.item(h)
+}
+
+// item converts the data item whose head h has just been read.
+func (rd *reader) item(h head) error {
+	switch h.major {
+	case majorUint:
+		return rd.emit(binpack.NewUint(h.arg, intTypes[h.size]))
+	case majorNegInt:
+		if h.arg == math.MaxUint64 {
+			return h.errorf("negative integer -2^64 overflows an Integer")
+		}
+		n := new(big.Int).Neg(new(big.Int).SetUint64(h.arg + 1))
+		return rd.emit(binpack.NewBigInt(n, intTypes[h.size]))
+	case majorBytes, majorText:
+		b, err := rd.str(h)
+		if err != nil {
+			return err
+		}
+		if h.major == majorText {
+			return rd.emit(binpack.NewString(string(b)))
+		}
+		return rd.emit(binpack.NewBlob(b))
+	case majorArray, majorMap, majorTag:
+		if rd.depth == maxDepth {
+			return h.errorf("nesting deeper than %d", maxDepth)
+		}
+		rd.depth++
+		var err error
+		if h.major == majorTag {
+			err = rd.tag(h)
+		} else {
+			err = rd.container(h)
+		}
+		rd.depth--
+		return err
+	}
+	switch {
+	case h.info == 20 || h.info == 21:
+		return rd.emit(binpack.NewBool(h.info == 21))
+	case h.info == 22 || h.info == 23:
+		return rd.emit(binpack.NewNil())
+	case h.info == 25:
+		return rd.emit(binpack.NewFloat(halfToFloat32(uint16(h.arg))))
+	case h.info == 26:
+		return rd.emit(binpack.NewFloat(math.Float32frombits(uint32(h.arg))))
+	case h.info == 27:
+		return rd.emit(binpack.NewDouble(math.Float64frombits(h.arg)))
+	case h.info == indefinite:
+		return h.errorf("unexpected break")
+	}
+	return h.errorf("unsupported simple value %d", h.arg)
+}
+
+// str reads the payload of a byte or text string, joining the chunks of
+// indefinite-length strings.
+func (rd *reader) str(h head) ([]byte, error) {
+	if h.info != indefinite {
+		return rd.readN(h.arg)
+	}
+	var b []byte
+	for {
+		c, err := rd.head()
+		if err != nil {
+			return nil, err
+		}
+		if c.major == majorSimple && c.info == indefinite {
+			return b, nil
+		}
+		if c.major != h.major || c.info == indefinite {
+			return nil, c.errorf("invalid chunk in indefinite-length string")
+		}
+		chunk, err := rd.readN(c.arg)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, chunk...)
+	}
+}
+
+// tag converts the data item following the tag whose head h has just been
+// read.
+func (rd *reader) tag(h head) error {
+	if rd.opts.Tags == RejectTags {
+		return h.errorf("unsupported tag %d", h.arg)
+	}
+	return rd.value()
+}
+
+// container converts an array to a List or a map to a Dict.
+func (rd *reader) container(h head) error {
+	code, n := binpack.List, h.arg
+	if h.major == majorMap {
+		code, n = binpack.Dict, 2*h.arg
+	}
+	rd.w.WriteByte(byte(code))
+	for i := uint64(0); h.info == indefinite || i < n; i++ {
+		c, err := rd.head()
+		if err != nil {
+			return err
+		}
+		if h.info == indefinite && c.major == majorSimple && c.info == indefinite {
+			if code == binpack.Dict && i%2 != 0 {
+				return c.errorf("map with a key but no value")
+			}
+			break
+		}
+		if err := rd.item(c); err != nil {
+			return err
+		}
+	}
+	return rd.w.WriteByte(byte(binpack.Closure))
+}
+
+// halfToFloat32 converts an IEEE 754 half precision float.
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch exp {
+	case 0: // zero or subnormal
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f: // infinity or NaN
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+}
+
+// writer writes the values it visits as CBOR.
+type writer struct {
+	w *bufio.Writer
+}
+
+// head writes an initial byte and argument, using an argument of size
+// bytes unless it fits the initial byte and size is 0.
+func (cw *writer) head(major byte, arg uint64, size int) {
+	if size == 0 {
+		if arg < 24 {
+			cw.w.WriteByte(major<<5 | byte(arg))
+			return
+		}
+		size = 8
+		switch {
+		case arg <= math.MaxUint8:
+			size = 1
+		case arg <= math.MaxUint16:
+			size = 2
+		case arg <= math.MaxUint32:
+			size = 4
+		}
+	}
+	info := byte(24)
+	for s := size; s > 1; s >>= 1 {
+		info++
+	}
+	cw.w.WriteByte(major<<5 | info)
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], arg)
+	cw.w.Write(b[8-size:])
+}
+
+func (cw *writer) OnListStart(path binpack.Path) error {
+	return cw.w.WriteByte(majorArray<<5 | indefinite)
+}
+
+func (cw *writer) OnDictStart(path binpack.Path) error {
+	return cw.w.WriteByte(majorMap<<5 | indefinite)
+}
+
+func (cw *writer) OnDictKey(path binpack.Path, key binpack.Value) error {
+	b, err := key.MarshalBinpack()
+	if err != nil {
+		return err
+	}
+	return binpack.Walk(b, cw)
+}
+
+func (cw *writer) OnClosure(path binpack.Path, code binpack.Code) error {
+	return cw.w.WriteByte(breakCode)
+}
+
+// intSizes maps binpack integer subtypes to the size of the argument
+// they are written with.
+var intSizes = map[binpack.Code]int{
+	binpack.IntegerTypeByte:  1,
+	binpack.IntegerTypeShort: 2,
+	binpack.IntegerTypeInt:   4,
+	binpack.IntegerTypeLong:  8,
+}
+
+func (cw *writer) OnScalar(path binpack.Path, code binpack.Code, value interface{}) error {
+	switch code {
+	case binpack.Nil:
+		return cw.w.WriteByte(majorSimple<<5 | 22)
+	case binpack.True:
+		return cw.w.WriteByte(majorSimple<<5 | 21)
+	case binpack.False:
+		return cw.w.WriteByte(majorSimple<<5 | 20)
+	case binpack.Float:
+		cw.head(majorSimple, uint64(math.Float32bits(value.(float32))), 4)
+	case binpack.Double:
+		cw.head(majorSimple, math.Float64bits(value.(float64)), 8)
+	case binpack.String:
+		s := value.(string)
+		cw.head(majorText, uint64(len(s)), 0)
+		cw.w.WriteString(s)
+	case binpack.Blob:
+		b := value.([]byte)
+		cw.head(majorBytes, uint64(len(b)), 0)
+		cw.w.Write(b)
+	default:
+		major, arg := byte(majorUint), uint64(0)
+		switch i := value.(type) {
+		case uint64:
+			arg = i
+		case int64:
+			arg = uint64(i)
+			if i < 0 {
+				major, arg = majorNegInt, uint64(-1-i)
+			}
+		case *big.Int: // below math.MinInt64
+			major = majorNegInt
+			arg = new(big.Int).Sub(new(big.Int).Neg(i), big.NewInt(1)).Uint64()
+		}
+		size := intSizes[code&binpack.MaskIntegerType]
+		switch {
+		case size == 8 && arg < 24:
+			size = 0
+		case size < 8 && arg>>uint(8*size) != 0:
+			size = 8 // does not fit its subtype
+		}
+		cw.head(major, arg, size)
+	}
+	return nil
+}
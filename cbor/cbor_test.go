@@ -0,0 +1,150 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/theodesp/binpack"
+)
+
+func TestToBinpack(t *testing.T) {
+	testCases := []struct {
+		in   string
+		opts Options
+		want string
+	}{
+		{"f4f5f6f7", Options{}, "false\ntrue\nnil\nnil"},
+		{"00171818", Options{}, "0\n23\n24i8"},
+		{"1901001a000f42401b000000e8d4a51000", Options{}, "256i16\n1000000i32\n1000000000000"},
+		{"2038ff3bfffffffffffffffe", Options{}, "-1\n-256i8\n-18446744073709551615"},
+		{"f93e00f90001fa3fc00000fb3ff8000000000000", Options{}, "1.5f\n5.9604645e-08f\n1.5f\n1.5"},
+		{"f97c00f9fc00", Options{}, "Infinityf\n-Infinityf"},
+		{"6361626343010203", Options{}, `"abc"` + "\n" + "h'010203'"},
+		{"7f6161626162ff5f4101ff", Options{}, `"aab"` + "\n" + "h'01'"},
+		{"8301820203a0", Options{}, "[1, [2, 3], {}]"},
+		{"9f019f02ffff", Options{}, "[1, [2]]"},
+		{"a26161f60102", Options{}, `{"a": nil, 1: 2}`},
+		{"bf6161f6ff", Options{}, `{"a": nil}`},
+		{"c11a514b67b0", Options{Tags: DropTags}, "1363896240i32"},
+		{"d82072687474703a2f2f6578616d706c652e636f6d", Options{Tags: DropTags}, `"http://example.com"`},
+	}
+	for _, test := range testCases {
+		in, _ := hex.DecodeString(test.in)
+		var out bytes.Buffer
+		if err := test.opts.ToBinpack(bytes.NewReader(in), &out); err != nil {
+			t.Fatalf("cbor:ToBinpack(%s) error %v", test.in, err)
+		}
+		if got := binpack.Format(out.Bytes()); got != test.want {
+			t.Fatalf("cbor:ToBinpack(%s) got %s; wanted %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestToBinpack_Errors(t *testing.T) {
+	testCases := []struct {
+		in  string
+		err string
+	}{
+		{"c11a514b67b0", "cbor: unsupported tag 1 at offset 0"},
+		{"82c0", "cbor: unsupported tag 0 at offset 1"},
+		{"3bffffffffffffffff", "cbor: negative integer -2^64 overflows an Integer at offset 0"},
+		{"1c", "cbor: reserved additional information 28 at offset 0"},
+		{"1f", "cbor: invalid indefinite length for major type 0 at offset 0"},
+		{"ff", "cbor: unexpected break at offset 0"},
+		{"f0", "cbor: unsupported simple value 16 at offset 0"},
+		{"5f6161ff", "cbor: invalid chunk in indefinite-length string at offset 1"},
+		{"bf01ff", "cbor: map with a key but no value at offset 2"},
+		{"8201", io.ErrUnexpectedEOF.Error()},
+		{"9f01", io.ErrUnexpectedEOF.Error()},
+		{"5bffffffffffffffff00", io.ErrUnexpectedEOF.Error()},
+		{"19", io.ErrUnexpectedEOF.Error()},
+		{strings.Repeat("81", 10001), "cbor: nesting deeper than 10000 at offset 10000"},
+	}
+	for _, test := range testCases {
+		in, _ := hex.DecodeString(test.in)
+		err := ToBinpack(bytes.NewReader(in), &bytes.Buffer{})
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("cbor:ToBinpack(%.20s) got error %v; wanted %s", test.in, err, test.err)
+		}
+	}
+
+	tags := bytes.Repeat([]byte{0xc0}, 1<<20)
+	err := Options{Tags: DropTags}.ToBinpack(bytes.NewReader(tags), &bytes.Buffer{})
+	if err == nil || err.Error() != "cbor: nesting deeper than 10000 at offset 10000" {
+		t.Fatalf("cbor:ToBinpack of deeply nested tags got error %v", err)
+	}
+}
+
+func TestFromBinpack(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{"nil true false", "f6f5f4"},
+		{"5 23 24 -1 -24 -25", "05171b000000000000001820373b0000000000000018"},
+		{"5i8 300i8 -5i16 7i32", "18051b000000000000012c3900041a00000007"},
+		{"-9223372036854775809 -18446744073709551615", "3b80000000000000003bfffffffffffffffe"},
+		{"1.5f 1.5", "fa3fc00000fb3ff8000000000000"},
+		{`"abc" h'0102'`, "63616263420102"},
+		{`[1, [], {"a": nil}]`, "9f019fffbf6161f6ffff"},
+		{`{[1]: 2}`, "bf9f01ff02ff"},
+	}
+	for _, test := range testCases {
+		data, err := binpack.Parse(test.in)
+		if err != nil {
+			t.Fatalf("binpack:Parse(%s) error %v", test.in, err)
+		}
+		var out bytes.Buffer
+		if err := FromBinpack(bytes.NewReader(data), &out); err != nil {
+			t.Fatalf("cbor:FromBinpack(%s) error %v", test.in, err)
+		}
+		if got := hex.EncodeToString(out.Bytes()); got != test.want {
+			t.Fatalf("cbor:FromBinpack(%s) got %s; wanted %s", test.in, got, test.want)
+		}
+	}
+	if err := FromBinpack(bytes.NewReader([]byte{0x02, 0x41}), &bytes.Buffer{}); err == nil {
+		t.Fatal("cbor:FromBinpack of truncated data expected error: got none")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	texts := []string{
+		`{"id": 1, "name": "ann", "tags": ["a", "b"], "ratio": 0.25, "f": 0.5f}`,
+		`[-9223372036854775808, 18446744073709551615, -256i8, 255i8, -65536i16, 65535i16, -4294967296i32, 4294967295i32]`,
+		`[-9223372036854775809, -18446744073709551614, -18446744073709551615]`,
+		`{h'00ff': [nil, true, false, {}], {1: 2}: 3}`,
+		`"` + strings.Repeat("x", 70000) + `"`,
+	}
+	for _, text := range texts {
+		data, err := binpack.Parse(text)
+		if err != nil {
+			t.Fatalf("binpack:Parse error %v", err)
+		}
+		var c, out bytes.Buffer
+		if err := FromBinpack(bytes.NewReader(data), &c); err != nil {
+			t.Fatalf("cbor:FromBinpack error %v", err)
+		}
+		if err := ToBinpack(&c, &out); err != nil {
+			t.Fatalf("cbor:ToBinpack error %v", err)
+		}
+		if !bytes.Equal(out.Bytes(), data) {
+			t.Fatalf("cbor round trip of %.80s got %.80s", text, binpack.Format(out.Bytes()))
+		}
+	}
+	for _, in := range []string{"3b7fffffffffffffff", "3b8000000000000000", "3bfffffffffffffffd", "3bfffffffffffffffe"} {
+		c, _ := hex.DecodeString(in)
+		var data, out bytes.Buffer
+		if err := ToBinpack(bytes.NewReader(c), &data); err != nil {
+			t.Fatalf("cbor:ToBinpack(%s) error %v", in, err)
+		}
+		if err := FromBinpack(&data, &out); err != nil {
+			t.Fatalf("cbor:FromBinpack of %s error %v", in, err)
+		}
+		if got := hex.EncodeToString(out.Bytes()); got != in {
+			t.Fatalf("cbor round trip of %s got %s", in, got)
+		}
+	}
+}
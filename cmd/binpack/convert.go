@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/theodesp/binpack"
+	"github.com/theodesp/binpack/cbor"
+	"github.com/theodesp/binpack/msgpack"
+)
+
+// A format converts its encoding to binpack and back.
+type format struct {
+	toBinpack   func(r io.Reader, w io.Writer) error
+	fromBinpack func(r io.Reader, w io.Writer) error
+}
+
+var formats = map[string]format{
+	"binpack": {copyStream, copyStream},
+	"json":    {binpack.FromJSON, binpack.ToJSON},
+	"msgpack": {msgpack.ToBinpack, msgpack.FromBinpack},
+	"cbor":    {cbor.ToBinpack, cbor.FromBinpack},
+}
+
+func copyStream(r io.Reader, w io.Writer) error {
+	_, err := io.Copy(w, r)
+	return err
+}
+
+// convert transcodes the inputs from one format to another, going through
+// binpack when neither of them is binpack.
+func convert(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("convert")
+	from := fs.String("from", "binpack", "input format: binpack, json, msgpack or cbor")
+	to := fs.String("to", "json", "output format: binpack, json, msgpack or cbor")
+	taggedBlobs := fs.Bool("tagged-blobs", false, `read and write JSON Blobs as {"$blob": "<base64>"}`)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	in, ok := formats[*from]
+	if !ok {
+		return usageError("unknown format " + *from)
+	}
+	out, ok := formats[*to]
+	if !ok {
+		return usageError("unknown format " + *to)
+	}
+	if *taggedBlobs {
+		opts := binpack.JSONOptions{Blobs: binpack.BlobTagged}
+		if *from == "json" {
+			in = format{opts.FromJSON, opts.ToJSON}
+		}
+		if *to == "json" {
+			out = format{opts.FromJSON, opts.ToJSON}
+		}
+	}
+
+	w := bufio.NewWriter(stdout)
+	err := eachInput(fs.Args(), stdin, func(name string, r io.Reader) error {
+		switch {
+		case *from == "binpack":
+			return out.fromBinpack(r, w)
+		case *to == "binpack":
+			return in.toBinpack(r, w)
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(in.toBinpack(r, pw))
+		}()
+		err := out.fromBinpack(pr, w)
+		pr.CloseWithError(io.ErrClosedPipe)
+		return err
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
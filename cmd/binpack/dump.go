@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/theodesp/binpack"
+)
+
+// maxValue is the largest value the commands read in one piece.
+const maxValue = 1 << 30
+
+// scanValues returns a Scanner splitting r into encoded values.
+func scanValues(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), maxValue)
+	sc.Split(binpack.ScanValues)
+	return sc
+}
+
+// dump prints every value in diagnostic notation.
+func dump(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("dump")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(stdout)
+	err := eachInput(fs.Args(), stdin, func(name string, r io.Reader) error {
+		sc := scanValues(r)
+		for sc.Scan() {
+			fmt.Fprintln(w, binpack.Format(sc.Bytes()))
+		}
+		if err := sc.Err(); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		return nil
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// hexDump prints the annotated bytes of every input.
+func hexDump(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("hex")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(stdout)
+	err := eachInput(fs.Args(), stdin, func(name string, r io.Reader) error {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if fs.NArg() > 1 {
+			fmt.Fprintf(w, "==> %s <==\n", name)
+		}
+		return binpack.Annotate(data, w)
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
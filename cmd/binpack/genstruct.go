@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	gofmt "go/format"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/theodesp/binpack"
+)
+
+// genStruct prints the Go types inferred from sample values.
+func genStruct(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("gen-struct")
+	pkg := fs.String("package", "main", "package name of the generated file")
+	typeName := fs.String("type", "Root", "name of the type of the samples")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	root := new(shape)
+	err := eachInput(fs.Args(), stdin, func(name string, r io.Reader) error {
+		sc := scanValues(r)
+		for sc.Scan() {
+			var v binpack.Value
+			if err := v.UnmarshalBinpack(sc.Bytes()); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			root.add(v)
+		}
+		if err := sc.Err(); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if root.n == 0 && !root.nullable {
+		return fmt.Errorf("no samples")
+	}
+
+	g := generator{names: make(map[string]bool)}
+	if root.kind == kindStruct {
+		g.define(*typeName, root)
+	} else {
+		g.names[*typeName] = true
+		g.decls = append(g.decls, "")
+		g.decls[0] = "type " + *typeName + " " + g.goType(root, *typeName, *typeName)
+	}
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Types inferred by binpack gen-struct.\n\npackage %s\n", *pkg)
+	for _, decl := range g.decls {
+		src.WriteString("\n" + decl + "\n")
+	}
+	out, err := gofmt.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(out)
+	return err
+}
+
+// shapeKind is the kind of Go type inferred for values, from the most
+// specific to interface{}.
+type shapeKind int
+
+const (
+	kindUnknown shapeKind = iota // only Nil, or nothing, was seen
+	kindBool
+	kindInt8
+	kindInt16
+	kindInt32
+	kindInt64
+	kindFloat32
+	kindFloat64
+	kindString
+	kindBytes
+	kindList
+	kindStruct
+	kindAny
+)
+
+var kindTypes = map[shapeKind]string{
+	kindUnknown: "interface{}",
+	kindBool:    "bool",
+	kindInt8:    "int8",
+	kindInt16:   "int16",
+	kindInt32:   "int32",
+	kindInt64:   "int64",
+	kindFloat32: "float32",
+	kindFloat64: "float64",
+	kindString:  "string",
+	kindBytes:   "[]byte",
+	kindAny:     "interface{}",
+}
+
+// A shape describes the values seen at one place of the samples.
+type shape struct {
+	kind     shapeKind
+	nullable bool          // Nil was seen
+	n        int           // number of other values seen
+	elem     *shape        // elements of Lists
+	fields   []*shapeField // entries of Dicts, in order of appearance
+}
+
+// A shapeField is a Dict key and the values seen for it.
+type shapeField struct {
+	key   string
+	shape shape
+	n     int // number of Dicts holding the key
+}
+
+// add adds the value v to the values seen.
+func (s *shape) add(v binpack.Value) {
+	if v.Kind() == binpack.Nil {
+		s.nullable = true
+		return
+	}
+	s.n++
+	if s.kind = widen(s.kind, kindOf(v)); s.kind == kindList {
+		if s.elem == nil {
+			s.elem = new(shape)
+		}
+		for i := 0; i < v.Len(); i++ {
+			s.elem.add(v.Index(i))
+		}
+	} else if s.kind == kindStruct {
+		for i := 0; i < v.Len(); i++ {
+			e := v.Entry(i)
+			f := s.field(e.Key.Str())
+			f.n++
+			f.shape.add(e.Value)
+		}
+	}
+}
+
+// field returns the field for the Dict key k, adding it if needed.
+func (s *shape) field(k string) *shapeField {
+	for _, f := range s.fields {
+		if f.key == k {
+			return f
+		}
+	}
+	f := &shapeField{key: k}
+	s.fields = append(s.fields, f)
+	return f
+}
+
+// kindOf returns the kind of Go type holding v. Dicts with keys other than
+// Strings can only be held by an interface{}.
+func kindOf(v binpack.Value) shapeKind {
+	switch v.Kind() {
+	case binpack.True, binpack.False:
+		return kindBool
+	case binpack.Integer:
+		switch v.IntType() {
+		case binpack.IntegerTypeByte:
+			return kindInt8
+		case binpack.IntegerTypeShort:
+			return kindInt16
+		case binpack.IntegerTypeInt:
+			return kindInt32
+		}
+		return kindInt64
+	case binpack.Float:
+		return kindFloat32
+	case binpack.Double:
+		return kindFloat64
+	case binpack.String:
+		return kindString
+	case binpack.Blob:
+		return kindBytes
+	case binpack.List:
+		return kindList
+	case binpack.Dict:
+		for i := 0; i < v.Len(); i++ {
+			if v.Entry(i).Key.Kind() != binpack.String {
+				return kindAny
+			}
+		}
+		return kindStruct
+	}
+	return kindAny
+}
+
+// widen returns the kind of a type holding values of kinds a and b:
+// integers widen to the widest integer and floats to float64, other
+// mixes to interface{}.
+func widen(a, b shapeKind) shapeKind {
+	switch {
+	case a == kindUnknown || a == b:
+		return b
+	case a >= kindInt8 && a <= kindInt64 && b >= kindInt8 && b <= kindInt64,
+		a >= kindFloat32 && a <= kindFloat64 && b >= kindFloat32 && b <= kindFloat64:
+		if a > b {
+			return a
+		}
+		return b
+	}
+	return kindAny
+}
+
+// generator writes the type declarations.
+type generator struct {
+	decls []string // in order of definition, the samples type first
+	names map[string]bool
+}
+
+// define declares a struct type for s, named name or, if name is taken,
+// after its parent, and returns the name.
+func (g *generator) define(name string, s *shape) string {
+	name = g.unique(name)
+	i := len(g.decls)
+	g.decls = append(g.decls, "")
+	var b strings.Builder
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	used := make(map[string]bool)
+	for _, f := range s.fields {
+		if !taggable(f.key) {
+			fmt.Fprintf(&b, "\t// The key %s is left out: it cannot be written in a field tag.\n", strconv.Quote(f.key))
+			continue
+		}
+		fname := goName(f.key)
+		for j := 2; used[fname]; j++ {
+			fname = goName(f.key) + strconv.Itoa(j)
+		}
+		used[fname] = true
+		typ := g.goType(&f.shape, fname, name)
+		optional := f.n < s.n
+		tag := f.key
+		if optional {
+			tag += ",omitempty"
+		}
+		if (optional || f.shape.nullable) && !nilable(typ) {
+			typ = "*" + typ
+		}
+		fmt.Fprintf(&b, "\t%s %s `binpack:%s`\n", fname, typ, strconv.Quote(tag))
+	}
+	b.WriteString("}")
+	g.decls[i] = b.String()
+	return name
+}
+
+// taggable reports whether the Dict key k can be the name in a binpack
+// field tag. An empty name or "-" means something else, a comma starts the
+// options and a backquote would end the tag literal.
+func taggable(k string) bool {
+	return k != "" && k != "-" && !strings.ContainsAny(k, ",`")
+}
+
+// goType returns the Go type holding the values of s, defining the types
+// it needs. Struct types are named name, or parent followed by name if it
+// is taken.
+func (g *generator) goType(s *shape, name, parent string) string {
+	switch s.kind {
+	case kindList:
+		return "[]" + g.goType(s.elem, singular(name), parent)
+	case kindStruct:
+		if g.names[name] {
+			name = parent + name
+		}
+		return g.define(name, s)
+	}
+	return kindTypes[s.kind]
+}
+
+// unique reserves a type name based on name.
+func (g *generator) unique(name string) string {
+	n := name
+	for i := 2; g.names[n]; i++ {
+		n = name + strconv.Itoa(i)
+	}
+	g.names[n] = true
+	return n
+}
+
+// nilable reports whether the Go type typ can already hold nil.
+func nilable(typ string) bool {
+	return strings.HasPrefix(typ, "[]") || typ == "interface{}"
+}
+
+// initialisms are written in upper case in Go names.
+var initialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "IP": true,
+	"JSON": true, "SQL": true, "URI": true, "URL": true, "UUID": true,
+}
+
+// goName returns an exported Go identifier for the Dict key k, such as
+// UserID for "user_id".
+func goName(k string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(k, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if up := strings.ToUpper(part); initialisms[up] {
+			b.WriteString(up)
+			continue
+		}
+		r := []rune(part)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	name := b.String()
+	if name == "" || !unicode.IsUpper([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// singular returns the name of an element of the List named name.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		return name[:len(name)-1]
+	}
+	return name + "Elem"
+}
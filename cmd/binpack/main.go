@@ -0,0 +1,147 @@
+// Command binpack inspects and converts binpack data.
+//
+// Usage:
+//
+//	binpack <command> [flags] [files]
+//
+// The commands are:
+//
+//	dump      print values in diagnostic notation, one per line
+//	hex       print an annotated hex dump explaining every byte
+//	convert   transcode between binpack, JSON, MessagePack and CBOR
+//	query     filter values with a jq-like query, see package query
+//	stats     summarize the types, sizes and keys of the values
+//	validate  check that the inputs are well-formed, reporting problems
+//	          as JSON lines
+//	gen-struct
+//	          print Go types inferred from sample values
+//
+// Every command reads the named files in turn, or the standard input when
+// no file or "-" is given. Inputs may hold any number of concatenated
+// values.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// A command runs a subcommand with its arguments, reading the standard
+// input from stdin and writing its output to stdout.
+type command struct {
+	run   func(args []string, stdin io.Reader, stdout io.Writer) error
+	usage string
+}
+
+var commands = map[string]command{
+	"dump":       {dump, "[files]"},
+	"hex":        {hexDump, "[files]"},
+	"convert":    {convert, "-from format -to format [files]"},
+	"query":      {queryValues, "[-o dump|json|binpack] query [files]"},
+	"stats":      {stats, "[-keys n] [files]"},
+	"validate":   {validate, "[-utf8] [files]"},
+	"gen-struct": {genStruct, "[-package name] [-type name] [files]"},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command line args and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "binpack: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	err := cmd.run(args[1:], stdin, stdout)
+	switch err := err.(type) {
+	case nil:
+		return 0
+	case usageError:
+		fmt.Fprintf(stderr, "binpack %s: %v\nusage: binpack %s %s\n", args[0], err, args[0], cmd.usage)
+		return 2
+	case exitError:
+		return int(err)
+	}
+	fmt.Fprintf(stderr, "binpack %s: %v\n", args[0], err)
+	return 1
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: binpack <command> [flags] [files]")
+	fmt.Fprintln(w, "commands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s %s\n", name, commands[name].usage)
+	}
+}
+
+// usageError reports a bad command line.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// exitError makes the command exit with a code without printing anything,
+// as the command has already reported the failure.
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+
+// newFlagSet returns a FlagSet for the command name whose errors are
+// returned rather than printed.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(discard{})
+	return fs
+}
+
+// parseFlags parses args with fs, turning errors into usage errors.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	return nil
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
+
+// eachInput calls fn with every input named in files, or with stdin when
+// files is empty. The name "-" also stands for stdin.
+func eachInput(files []string, stdin io.Reader, fn func(name string, r io.Reader) error) error {
+	if len(files) == 0 {
+		return fn("-", stdin)
+	}
+	for _, name := range files {
+		if name == "-" {
+			if err := fn(name, stdin); err != nil {
+				return err
+			}
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = fn(name, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
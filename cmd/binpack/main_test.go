@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/theodesp/binpack"
+)
+
+// runCmd runs the command line args with stdin and returns the exit code
+// and the output.
+func runCmd(args []string, stdin []byte) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, bytes.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestDump(t *testing.T) {
+	code, out, _ := runCmd([]string{"dump"}, mustHex("03216102414101010f4d"))
+	if code != 0 || out != "{\"a\": [1, 1]}\nnil\n5i8\n" {
+		t.Fatalf("binpack dump got %d, %q", code, out)
+	}
+	code, out, errOut := runCmd([]string{"dump"}, mustHex("410241"))
+	if code != 1 || out != "1\n" || !strings.Contains(errOut, "unexpected EOF") {
+		t.Fatalf("binpack dump of truncated input got %d, %q, %q", code, out, errOut)
+	}
+}
+
+func TestDump_Files(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binpack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	ioutil.WriteFile(a, mustHex("41"), 0666)
+	ioutil.WriteFile(b, mustHex("2161"), 0666)
+	code, out, _ := runCmd([]string{"dump", a, "-", b}, mustHex("04"))
+	if code != 0 || out != "1\ntrue\n\"a\"\n" {
+		t.Fatalf("binpack dump of files got %d, %q", code, out)
+	}
+	code, _, errOut := runCmd([]string{"dump", filepath.Join(dir, "missing")}, nil)
+	if code != 1 || !strings.Contains(errOut, "missing") {
+		t.Fatalf("binpack dump of a missing file got %d, %q", code, errOut)
+	}
+}
+
+func TestHex(t *testing.T) {
+	code, out, _ := runCmd([]string{"hex"}, mustHex("020f01"))
+	want := "00000000  02                       List\n" +
+		"00000001  0f                         Nil\n" +
+		"00000002  01                       Closure (end List @0x0)\n"
+	if code != 0 || out != want {
+		t.Fatalf("binpack hex got %d, %q; wanted %q", code, out, want)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	testCases := []struct {
+		args []string
+		in   []byte
+		want []byte
+	}{
+		{[]string{"convert", "-from", "json", "-to", "binpack"}, []byte(`{"a": 1} [true]`), mustHex("0321614101020401")},
+		{[]string{"convert", "--from=binpack", "--to=json"}, mustHex("0321614101020401"), []byte("{\"a\":1}\n[true]\n")},
+		{[]string{"convert"}, mustHex("1101"), []byte("\"AQ==\"\n")},
+		{[]string{"convert", "-to", "json", "-tagged-blobs"}, mustHex("1101"), []byte("{\"$blob\":\"AQ==\"}\n")},
+		{[]string{"convert", "-from", "json", "-to", "msgpack"}, []byte(`[1, "a"]`), mustHex("9201a161")},
+		{[]string{"convert", "-from", "msgpack", "-to", "cbor"}, mustHex("9201a161"), mustHex("9f016161ff")},
+		{[]string{"convert", "-from", "cbor", "-to", "json"}, mustHex("9f016161ff"), []byte("[1,\"a\"]\n")},
+		{[]string{"convert", "-from", "binpack", "-to", "binpack"}, mustHex("41"), mustHex("41")},
+	}
+	for _, test := range testCases {
+		code, out, errOut := runCmd(test.args, test.in)
+		if code != 0 || out != string(test.want) {
+			t.Fatalf("binpack %v got %d, %q, %s; wanted %q", test.args, code, out, errOut, test.want)
+		}
+	}
+
+	code, _, errOut := runCmd([]string{"convert", "-from", "yaml"}, nil)
+	if code != 2 || !strings.Contains(errOut, "unknown format yaml") {
+		t.Fatalf("binpack convert -from yaml got %d, %q", code, errOut)
+	}
+	code, _, errOut = runCmd([]string{"convert", "-from", "json", "-to", "cbor"}, []byte("[1,"))
+	if code != 1 || errOut == "" {
+		t.Fatalf("binpack convert of invalid JSON got %d, %q", code, errOut)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	in := mustHex("03216102414201010321614101")
+	testCases := []struct {
+		args []string
+		want []byte
+	}{
+		{[]string{"query", ".a"}, []byte("[1, 2]\n1\n")},
+		{[]string{"query", ".a[]"}, []byte("1\n2\n")},
+		{[]string{"query", "select(.a == 1) | {b: .a}"}, []byte("{\"b\": 1}\n")},
+		{[]string{"query", "-o", "json", ".a"}, []byte("[1,2]\n1\n")},
+		{[]string{"query", "-o", "binpack", ".a"}, mustHex("0241420141")},
+	}
+	for _, test := range testCases {
+		code, out, errOut := runCmd(test.args, in)
+		if code != 0 || out != string(test.want) {
+			t.Fatalf("binpack %v got %d, %q, %s; wanted %q", test.args, code, out, errOut, test.want)
+		}
+	}
+
+	code, _, errOut := runCmd([]string{"query", ".a |"}, in)
+	if code != 2 || !strings.Contains(errOut, "unexpected end of query") {
+		t.Fatalf("binpack query of an invalid query got %d, %q", code, errOut)
+	}
+	code, _, errOut = runCmd([]string{"query"}, in)
+	if code != 2 || !strings.Contains(errOut, "missing query") {
+		t.Fatalf("binpack query without a query got %d, %q", code, errOut)
+	}
+	code, out, errOut := runCmd([]string{"query", ".a"}, mustHex("032161410102"))
+	if code != 1 || out != "1\n" || !strings.Contains(errOut, "unexpected EOF") {
+		t.Fatalf("binpack query of truncated input got %d, %q, %q", code, out, errOut)
+	}
+}
+
+func TestStats(t *testing.T) {
+	code, out, errOut := runCmd([]string{"stats"}, mustHex("03216141011501020304050f"))
+	want := `values     3
+bytes      12
+max depth  1
+
+codes:
+  Dict          1
+  Nil           1
+  Blob          1
+  String        1
+  Integer Long  1
+
+String lengths: 1 values, mean 1.0, max 1
+  1  1
+
+Blob lengths: 1 values, mean 5.0, max 5
+  4-7  1
+
+most frequent keys:
+  "a"  1
+
+bytes by top-level path:
+  .   9  75.0%
+  .a  3  25.0%
+`
+	if code != 0 || out != want {
+		t.Fatalf("binpack stats got %d, %s\n%s; wanted\n%s", code, errOut, out, want)
+	}
+	code, _, errOut = runCmd([]string{"stats"}, mustHex("0241"))
+	if code != 1 || !strings.Contains(errOut, "unexpected EOF") {
+		t.Fatalf("binpack stats of truncated input got %d, %q", code, errOut)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	code, out, errOut := runCmd([]string{"validate"}, mustHex("0321614101410f"))
+	if code != 0 || out != "" || errOut != "" {
+		t.Fatalf("binpack validate of valid input got %d, %q, %q", code, out, errOut)
+	}
+
+	dir, err := ioutil.TempDir("", "binpack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a")
+	ioutil.WriteFile(a, mustHex("032161020b01"), 0666)
+	code, out, errOut = runCmd([]string{"validate", "-utf8", a, "-"}, mustHex("21ff2361"))
+	want := `{"file":"` + a + `","offset":4,"path":".a[0]","reason":"reserved code 0x0b"}` + "\n" +
+		`{"file":"-","offset":0,"path":".","reason":"invalid UTF-8 in String"}` + "\n" +
+		`{"file":"-","offset":2,"path":".","reason":"String payload truncated: 1 of 3 bytes"}` + "\n"
+	if code != 1 || out != want || errOut != "" {
+		t.Fatalf("binpack validate got %d, %q, %q; wanted %q", code, out, errOut, want)
+	}
+}
+
+func TestGenStruct(t *testing.T) {
+	in, err := binpack.Parse(`{"user_id": 1, "name": "ann", "tags": ["a"], "home": {"city": "x"}, "n": 1i8}` +
+		` {"user_id": 2, "name": nil, "tags": [], "home": {"zip-code": 1}, "n": 300i16, "x": [1, "a"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, out, errOut := runCmd([]string{"gen-struct", "-package", "events", "-type", "Event"}, in)
+	want := "// Types inferred by binpack gen-struct.\n\npackage events\n\n" +
+		"type Event struct {\n" +
+		"\tUserID int64         `binpack:\"user_id\"`\n" +
+		"\tName   *string       `binpack:\"name\"`\n" +
+		"\tTags   []string      `binpack:\"tags\"`\n" +
+		"\tHome   Home          `binpack:\"home\"`\n" +
+		"\tN      int16         `binpack:\"n\"`\n" +
+		"\tX      []interface{} `binpack:\"x,omitempty\"`\n" +
+		"}\n\n" +
+		"type Home struct {\n" +
+		"\tCity    *string `binpack:\"city,omitempty\"`\n" +
+		"\tZipCode *int64  `binpack:\"zip-code,omitempty\"`\n" +
+		"}\n"
+	if code != 0 || out != want {
+		t.Fatalf("binpack gen-struct got %d, %s\n%s; wanted\n%s", code, errOut, out, want)
+	}
+
+	in, err = binpack.Parse("{\"a,b\": 1, \"-\": 2, \"\": 3, \"c`d\": 4, \"e\": 5}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, out, errOut = runCmd([]string{"gen-struct"}, in)
+	want = "// Types inferred by binpack gen-struct.\n\npackage main\n\n" +
+		"type Root struct {\n" +
+		"\t// The key \"a,b\" is left out: it cannot be written in a field tag.\n" +
+		"\t// The key \"-\" is left out: it cannot be written in a field tag.\n" +
+		"\t// The key \"\" is left out: it cannot be written in a field tag.\n" +
+		"\t// The key \"c`d\" is left out: it cannot be written in a field tag.\n" +
+		"\tE int64 `binpack:\"e\"`\n" +
+		"}\n"
+	if code != 0 || out != want {
+		t.Fatalf("binpack gen-struct of untaggable keys got %d, %s\n%s; wanted\n%s", code, errOut, out, want)
+	}
+
+	code, out, _ = runCmd([]string{"gen-struct"}, mustHex("0241420102216101"))
+	if want := "// Types inferred by binpack gen-struct.\n\npackage main\n\ntype Root []interface{}\n"; code != 0 || out != want {
+		t.Fatalf("binpack gen-struct of Lists got %d, %q; wanted %q", code, out, want)
+	}
+	code, _, errOut = runCmd([]string{"gen-struct"}, nil)
+	if code != 1 || !strings.Contains(errOut, "no samples") {
+		t.Fatalf("binpack gen-struct without samples got %d, %q", code, errOut)
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	for _, args := range [][]string{nil, {"help"}, {"frobnicate"}, {"dump", "-x"}} {
+		code, _, errOut := runCmd(args, nil)
+		if code != 2 || !strings.Contains(errOut, "usage: binpack") {
+			t.Fatalf("binpack %v got %d, %q", args, code, errOut)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/theodesp/binpack"
+	"github.com/theodesp/binpack/query"
+)
+
+// queryValues prints the results of a query applied to every value.
+func queryValues(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("query")
+	output := fs.String("o", "dump", "output format: dump, json or binpack")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError("missing query")
+	}
+	q, err := query.Parse(fs.Arg(0))
+	if err != nil {
+		return usageError(err.Error())
+	}
+	w := bufio.NewWriter(stdout)
+	var emit func(v binpack.Value) error
+	switch *output {
+	case "dump":
+		emit = func(v binpack.Value) error {
+			_, err := fmt.Fprintln(w, v)
+			return err
+		}
+	case "json", "binpack":
+		emit = func(v binpack.Value) error {
+			data, err := v.MarshalBinpack()
+			if err != nil {
+				return err
+			}
+			if *output == "json" {
+				return binpack.ToJSON(bytes.NewReader(data), w)
+			}
+			_, err = w.Write(data)
+			return err
+		}
+	default:
+		return usageError("unknown output format " + *output)
+	}
+	err = eachInput(fs.Args()[1:], stdin, func(name string, r io.Reader) error {
+		if err := q.Run(r, emit); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		return nil
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/theodesp/binpack"
+)
+
+// stats prints a summary of the values of all the inputs.
+func stats(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("stats")
+	keys := fs.Int("keys", 10, "number of most frequent Dict keys to print")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var s binpack.Stats
+	err := eachInput(fs.Args(), stdin, func(name string, r io.Reader) error {
+		if _, err := s.ReadFrom(r); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(stdout)
+	w := tabwriter.NewWriter(bw, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "values\t%d\n", s.Values)
+	fmt.Fprintf(w, "bytes\t%d\n", s.Bytes)
+	fmt.Fprintf(w, "max depth\t%d\n", s.MaxDepth)
+
+	fmt.Fprintln(w, "\ncodes:")
+	codes := make([]binpack.Code, 0, len(s.Codes))
+	for c := range s.Codes {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if s.Codes[codes[i]] != s.Codes[codes[j]] {
+			return s.Codes[codes[i]] > s.Codes[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	for _, c := range codes {
+		fmt.Fprintf(w, "  %s\t%d\n", codeName(c), s.Codes[c])
+	}
+
+	lengths(w, "String", s.StringLengths)
+	lengths(w, "Blob", s.BlobLengths)
+
+	if top := s.TopKeys(*keys); len(top) > 0 {
+		fmt.Fprintln(w, "\nmost frequent keys:")
+		for _, k := range top {
+			fmt.Fprintf(w, "  %s\t%d\n", k.Key, k.Count)
+		}
+	}
+
+	fmt.Fprintln(w, "\nbytes by top-level path:")
+	paths := make([]string, 0, len(s.PathBytes))
+	for p := range s.PathBytes {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if s.PathBytes[paths[i]] != s.PathBytes[paths[j]] {
+			return s.PathBytes[paths[i]] > s.PathBytes[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+	for _, p := range paths {
+		n := s.PathBytes[p]
+		fmt.Fprintf(w, "  %s\t%d\t%.1f%%\n", p, n, 100*float64(n)/float64(s.Bytes))
+	}
+
+	w.Flush()
+	return bw.Flush()
+}
+
+// codeName returns the name of c, with the subtype of Integers.
+func codeName(c binpack.Code) string {
+	if c&binpack.Integer == 0 {
+		return c.String()
+	}
+	switch c & binpack.MaskIntegerType {
+	case binpack.IntegerTypeByte:
+		return "Integer Byte"
+	case binpack.IntegerTypeShort:
+		return "Integer Short"
+	case binpack.IntegerTypeInt:
+		return "Integer Int"
+	}
+	return "Integer Long"
+}
+
+// lengths prints the distribution of the lengths of the Strings or Blobs.
+func lengths(w io.Writer, what string, h binpack.LengthHistogram) {
+	if h.Count == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s lengths: %d values, mean %.1f, max %d\n", what, h.Count, float64(h.Total)/float64(h.Count), h.Max)
+	for i, n := range h.Buckets {
+		if n == 0 {
+			continue
+		}
+		r := "0"
+		if lo, hi := 1<<uint(i)>>1, 1<<uint(i)-1; lo == hi {
+			r = fmt.Sprint(lo)
+		} else if i > 0 {
+			r = fmt.Sprintf("%d-%d", lo, hi)
+		}
+		fmt.Fprintf(w, "  %s\t%d\n", r, n)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/theodesp/binpack"
+)
+
+// problem is a line of the report of validate.
+type problem struct {
+	File   string `json:"file"`
+	Offset int64  `json:"offset"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// validate reports the problems of every input as JSON lines and fails if
+// there are any.
+func validate(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("validate")
+	utf8 := fs.Bool("utf8", false, "report Strings that are not valid UTF-8")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(stdout)
+	enc := json.NewEncoder(w)
+	failed := false
+	err := eachInput(fs.Args(), stdin, func(name string, r io.Reader) error {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		for _, e := range binpack.Validate(data, binpack.ValidateOptions{UTF8: *utf8}) {
+			failed = true
+			if err := enc.Encode(problem{name, e.Offset, e.Path.String(), e.Reason}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if err == nil && failed {
+		err = exitError(1)
+	}
+	return err
+}
@@ -0,0 +1,220 @@
+package binpack
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+)
+
+// CompareOptions configures Equal and Diff.
+type CompareOptions struct {
+	// FloatEqualsDouble makes a Float equal to a Double that holds the same
+	// number. By default they are different values.
+	FloatEqualsDouble bool
+}
+
+// Equal reports whether a and b encode the same value. Unlike comparing the
+// bytes, the order of Dict entries does not matter, so two encodings of the
+// same Go map are always equal. Malformed encodings are never equal.
+func Equal(a, b []byte) bool {
+	return CompareOptions{}.Equal(a, b)
+}
+
+// Diff returns the changes that turn the value encoded in a into the value
+// encoded in b, addressed by their paths. Dict entries are matched by key
+// and List elements by index.
+func Diff(a, b []byte) ([]Change, error) {
+	return CompareOptions{}.Diff(a, b)
+}
+
+// Equal is like the package level Equal, using the options.
+func (o CompareOptions) Equal(a, b []byte) bool {
+	var va, vb Value
+	if va.UnmarshalBinpack(a) != nil || vb.UnmarshalBinpack(b) != nil {
+		return false
+	}
+	return o.equal(va, vb)
+}
+
+// Diff is like the package level Diff, using the options.
+func (o CompareOptions) Diff(a, b []byte) ([]Change, error) {
+	var va, vb Value
+	if err := va.UnmarshalBinpack(a); err != nil {
+		return nil, err
+	}
+	if err := vb.UnmarshalBinpack(b); err != nil {
+		return nil, err
+	}
+	var changes []Change
+	o.diff(nil, va, vb, &changes)
+	return changes, nil
+}
+
+// ChangeType tells how a value differs between two documents.
+type ChangeType int
+
+const (
+	Added    ChangeType = iota + 1 // the value only exists in the second document
+	Removed                        // the value only exists in the first document
+	Modified                       // the value differs between the documents
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return "ChangeType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// A Change is a difference found by Diff. Old is the Nil Value for Added
+// changes and New is the Nil Value for Removed changes.
+type Change struct {
+	Path Path
+	Type ChangeType
+	Old  Value
+	New  Value
+}
+
+func (o CompareOptions) diff(path Path, a, b Value, changes *[]Change) {
+	switch {
+	case a.Kind() == Dict && b.Kind() == Dict:
+		bkeys := newKeyIndex(b)
+		seen := make([]bool, len(b.dict))
+		for _, e := range a.dict {
+			elem := pathElem(e.Key)
+			if i := bkeys.find(o, e.Key); i >= 0 {
+				seen[i] = true
+				o.diff(path.append(elem), e.Value, b.dict[i].Value, changes)
+			} else {
+				*changes = append(*changes, Change{Path: path.append(elem), Type: Removed, Old: e.Value})
+			}
+		}
+		for i, e := range b.dict {
+			if !seen[i] {
+				*changes = append(*changes, Change{Path: path.append(pathElem(e.Key)), Type: Added, New: e.Value})
+			}
+		}
+	case a.Kind() == List && b.Kind() == List:
+		for i := 0; i < len(a.list) || i < len(b.list); i++ {
+			switch {
+			case i >= len(b.list):
+				*changes = append(*changes, Change{Path: path.append(i), Type: Removed, Old: a.list[i]})
+			case i >= len(a.list):
+				*changes = append(*changes, Change{Path: path.append(i), Type: Added, New: b.list[i]})
+			default:
+				o.diff(path.append(i), a.list[i], b.list[i], changes)
+			}
+		}
+	case !o.equal(a, b):
+		*changes = append(*changes, Change{Path: path, Type: Modified, Old: a, New: b})
+	}
+}
+
+// pathElem returns the Path element that selects the entry with key k.
+func pathElem(k Value) interface{} {
+	if k.Kind() == String {
+		return k.s
+	}
+	return k
+}
+
+// equal reports whether a and b are the same value, ignoring the order of
+// Dict entries.
+func (o CompareOptions) equal(a, b Value) bool {
+	ka, kb := a.Kind(), b.Kind()
+	if o.FloatEqualsDouble && (ka == Float || ka == Double) && (kb == Float || kb == Double) {
+		fa, fb := a.Float(), b.Float()
+		return fa == fb || math.IsNaN(fa) && math.IsNaN(fb)
+	}
+	if ka != kb {
+		return false
+	}
+	switch ka {
+	case Integer:
+		return a.itype == b.itype && a.n == b.n && (a.neg == b.neg || a.n == 0)
+	case Float, Double:
+		fa, fb := a.Float(), b.Float()
+		return fa == fb || math.IsNaN(fa) && math.IsNaN(fb)
+	case String:
+		return a.s == b.s
+	case Blob:
+		return bytes.Equal(a.b, b.b)
+	case List:
+		if len(a.list) != len(b.list) {
+			return false
+		}
+		for i := range a.list {
+			if !o.equal(a.list[i], b.list[i]) {
+				return false
+			}
+		}
+	case Dict:
+		if len(a.dict) != len(b.dict) {
+			return false
+		}
+		// Each entry of b matches at most one entry of a, so that
+		// duplicate keys are counted.
+		bkeys := newKeyIndex(b)
+		used := make([]bool, len(b.dict))
+		for _, e := range a.dict {
+			i := bkeys.match(o, e, used)
+			if i < 0 {
+				return false
+			}
+			used[i] = true
+		}
+	}
+	return true
+}
+
+// keyIndex finds the entries of a Dict by key.
+type keyIndex struct {
+	dict    []Entry
+	strings map[string]int // index of the first entry with each String key
+}
+
+func newKeyIndex(v Value) keyIndex {
+	k := keyIndex{dict: v.dict, strings: make(map[string]int, len(v.dict))}
+	for i, e := range v.dict {
+		if _, dup := k.strings[e.Key.s]; e.Key.code == String && !dup {
+			k.strings[e.Key.s] = i
+		}
+	}
+	return k
+}
+
+// find returns the index of the first entry whose key equals key, or -1.
+func (k keyIndex) find(o CompareOptions, key Value) int {
+	if key.Kind() == String {
+		if i, ok := k.strings[key.s]; ok {
+			return i
+		}
+		return -1
+	}
+	for i, e := range k.dict {
+		if o.equal(e.Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// match returns the index of the first entry not yet used whose key and
+// value equal those of e, or -1.
+func (k keyIndex) match(o CompareOptions, e Entry, used []bool) int {
+	i := k.find(o, e.Key)
+	if i < 0 {
+		return -1
+	}
+	for ; i < len(k.dict); i++ {
+		if !used[i] && o.equal(k.dict[i].Key, e.Key) && o.equal(k.dict[i].Value, e.Value) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,140 @@
+package binpack
+
+import (
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	m := map[string]interface{}{"a": 1, "b": []int{1, 2}, "c": map[int]string{1: "x", 2: "y"}, "d": "", "e": nil}
+	a, _ := Marshal(m)
+	for i := 0; i < 20; i++ {
+		b, _ := Marshal(m)
+		if !Equal(a, b) {
+			t.Fatalf("binpack:Equal reports encodings of the same map differ: %x, %x", a, b)
+		}
+	}
+
+	testCases := []struct {
+		a, b  interface{}
+		equal bool
+		loose bool
+	}{
+		{1, 1, true, true},
+		{1, 2, false, false},
+		{int8(1), int64(1), false, false},
+		{float32(1.5), 1.5, false, true},
+		{float32(0.1), 0.1, false, false},
+		{"a", []byte("a"), false, false},
+		{[]int{1, 2}, []int{2, 1}, false, false},
+		{map[string]int{"a": 1}, map[string]int{"a": 1, "b": 2}, false, false},
+		{map[string]int{"a": 1}, map[string]int{"b": 1}, false, false},
+		{map[string]interface{}{"a": float32(2)}, map[string]interface{}{"a": 2.0}, false, true},
+	}
+	loose := CompareOptions{FloatEqualsDouble: true}
+	for _, test := range testCases {
+		a, _ := Marshal(test.a)
+		b, _ := Marshal(test.b)
+		if got := Equal(a, b); got != test.equal {
+			t.Fatalf("binpack:Equal(%v, %v) got %v; wanted %v", test.a, test.b, got, test.equal)
+		}
+		if got := loose.Equal(a, b); got != test.loose {
+			t.Fatalf("CompareOptions:Equal(%v, %v) got %v; wanted %v", test.a, test.b, got, test.loose)
+		}
+	}
+	if Equal([]byte{0x02}, []byte{0x02}) {
+		t.Fatal("binpack:Equal reports malformed encodings equal")
+	}
+
+	duplicates := []struct {
+		a, b  string
+		equal bool
+	}{
+		{`{"k": 1, "k": 1}`, `{"k": 1, "j": 2}`, false},
+		{`{"k": 1, "k": 1}`, `{"k": 1, "k": 2}`, false},
+		{`{"k": 1, "k": 2}`, `{"k": 2, "k": 1}`, true},
+	}
+	for _, test := range duplicates {
+		a, _ := Parse(test.a)
+		b, _ := Parse(test.b)
+		if got := Equal(a, b); got != test.equal {
+			t.Fatalf("binpack:Equal(%s, %s) got %v; wanted %v", test.a, test.b, got, test.equal)
+		}
+		if got := Equal(b, a); got != test.equal {
+			t.Fatalf("binpack:Equal(%s, %s) got %v; wanted %v", test.b, test.a, got, test.equal)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a, _ := Marshal(map[string]interface{}{
+		"name":  "ann",
+		"tags":  []string{"x", "y"},
+		"meta":  map[string]int{"v": 1},
+		"token": "secret",
+	})
+	b, _ := Marshal(map[string]interface{}{
+		"name":    "ann",
+		"tags":    []string{"x", "z", "w"},
+		"meta":    map[string]int{"v": 2},
+		"my key":  true,
+		"numbers": map[int]int{5: 1},
+	})
+	changes, err := Diff(a, b)
+	if err != nil {
+		t.Fatalf("binpack:Diff error %v", err)
+	}
+	got := map[string]ChangeType{}
+	for _, c := range changes {
+		got[c.Path.String()] = c.Type
+	}
+	want := map[string]ChangeType{
+		".tags[1]":   Modified,
+		".tags[2]":   Added,
+		".meta.v":    Modified,
+		".token":     Removed,
+		`["my key"]`: Added,
+		".numbers":   Added,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("binpack:Diff got %v; wanted %v", got, want)
+	}
+	for path, typ := range want {
+		if got[path] != typ {
+			t.Fatalf("binpack:Diff change at %s got %v; wanted %v", path, got[path], typ)
+		}
+	}
+	for _, c := range changes {
+		if c.Path.String() == ".meta.v" && (c.Old.Int() != 1 || c.New.Int() != 2) {
+			t.Fatalf("binpack:Diff change at .meta.v got %v -> %v", c.Old.Int(), c.New.Int())
+		}
+	}
+
+	if changes, _ := Diff(a, a); len(changes) != 0 {
+		t.Fatalf("binpack:Diff of a document with itself got %v", changes)
+	}
+	c, _ := Marshal(map[int]int{5: 1})
+	d, _ := Marshal(map[int]int{5: 2})
+	if changes, _ := Diff(c, d); len(changes) != 1 || changes[0].Path.String() != "{5}" {
+		t.Fatalf("binpack:Diff with Integer keys got %v", changes)
+	}
+	if _, err := Diff([]byte{0x02}, a); err == nil {
+		t.Fatal("binpack:Diff of malformed data expected error: got none")
+	}
+}
+
+func TestPath_String(t *testing.T) {
+	testCases := []struct {
+		in   Path
+		want string
+	}{
+		{nil, "."},
+		{Path{"users", 3, "name"}, ".users[3].name"},
+		{Path{"a b", "_x1", "1x"}, `["a b"]._x1["1x"]`},
+		{Path{NewInt(-2, IntegerTypeLong)}, "{-2}"},
+	}
+	for _, test := range testCases {
+		if got := test.in.String(); got != test.want {
+			t.Fatalf("Path:String got %s; wanted %s", got, test.want)
+		}
+	}
+}
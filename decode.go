@@ -36,6 +36,19 @@ func (d *decBuffer) Size(n int) {
 	}
 }
 
+// Grow extends the buffer by n bytes and returns them so the caller can fill
+// them in. Existing data is kept.
+func (d *decBuffer) Grow(n int) []byte {
+	l := len(d.data)
+	if cap(d.data)-l < n {
+		data := make([]byte, l, 2*cap(d.data)+n)
+		copy(data, d.data)
+		d.data = data
+	}
+	d.data = d.data[0 : l+n]
+	return d.data[l:]
+}
+
 func (d *decBuffer) ReadByte() (byte, error) {
 	if d.offset >= len(d.data) {
 		return 0, io.EOF
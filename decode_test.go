@@ -85,3 +85,15 @@ func TestDecBuffer_Read(t *testing.T) {
 		t.Fatalf("decBuffer:Read expected to read %v bytes: got %v", 3, n)
 	}
 }
+
+func TestDecBuffer_Grow(t *testing.T) {
+	db := decBuffer{}
+	copy(db.Grow(2), "ab")
+	copy(db.Grow(100), "cd")
+	if db.Len() != 102 {
+		t.Fatalf("decBuffer:Len expected length after Grow %v: got %v", 102, db.Len())
+	}
+	if b := db.Bytes(); string(b[:4]) != "abcd" {
+		t.Fatalf("decBuffer:Grow expected %q: got %q", "abcd", b[:4])
+	}
+}
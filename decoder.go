@@ -2,9 +2,15 @@ package binpack
 
 import (
 	"bufio"
+	"encoding/binary"
 	"errors"
 	"io"
+	"io/ioutil"
+	"math"
 	"reflect"
+	"unsafe"
+
+	"github.com/theodesp/binpack/internal/structs"
 )
 
 // A Decoder parses a decoded message and unpacks its values into the assigned variables.
@@ -13,6 +19,7 @@ import (
 type Decoder struct {
 	r   io.Reader // source of the data
 	buf decBuffer // buffer for more efficient i/o from r
+	off int64     // number of bytes consumed by previous values
 	err error     // handle reader errors
 }
 
@@ -65,25 +72,606 @@ func (dec *Decoder) DecodeValue(v reflect.Value) error {
 		}
 	}
 
+	if !v.IsValid() {
+		return dec.Skip()
+	}
+
 	dec.buf.Reset() // In case data lingers from previous invocation.
-	dec.err = nil
-	id := dec.decodeType(false)
+	dec.err = dec.scanValue(true)
 	if dec.err == nil {
-		dec.decode(id, v)
+		dec.decode(v)
 	}
 	return dec.err
 }
 
-// decodeType parses
-// and returns the type id of the next value. It returns 0 at
-// EOF.  Upon return, the remainder of dec.buf is the value to be
-// decoded. If this is an interface value, it can be ignored by
-// resetting that buffer.
-func (dec *Decoder) decodeType(isInterface bool) Code {
-	return 0
+// Skip discards the next value in the input stream without decoding it.
+// Nested Lists and Dicts are walked to their matching Closure and the
+// payloads of Strings and Blobs are skipped using their length prefix.
+// If the input is at EOF, Skip returns io.EOF.
+func (dec *Decoder) Skip() error {
+	dec.buf.Reset()
+	dec.err = dec.scanValue(false)
+	return dec.err
+}
+
+// scanValue reads the next complete value from the input. If keep is set
+// the value is stored in dec.buf, otherwise it is discarded as it is read.
+// It returns io.EOF if the input ends before the value starts and
+// io.ErrUnexpectedEOF if it ends inside the value.
+func (dec *Decoder) scanValue(keep bool) error {
+	return dec.scanRest(0, keep)
 }
 
-// decode decodes the data stream representing a value and stores it in value.
-func (dec *Decoder) decode(_ Code, _ reflect.Value) {
-	defer catchError(&dec.err)
+// scanRest is like scanValue, but starts inside depth Lists or Dicts whose
+// headers have already been read, and reads up to their matching Closure.
+func (dec *Decoder) scanRest(depth int, keep bool) error {
+	for {
+		h, err := dec.readHeader()
+		if err == io.EOF && (depth > 0 || dec.buf.Len() > 0) {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		switch h.code {
+		case List, Dict:
+			depth++
+		case Closure:
+			if depth == 0 {
+				return syntaxErrorf(int(dec.off)+dec.buf.Len()-1, "unexpected Closure")
+			}
+			depth--
+		}
+		if keep {
+			err = dec.readPayload(h.payload())
+		} else {
+			dec.off += int64(dec.buf.Len())
+			dec.buf.Reset()
+			err = dec.discard(h.payload())
+		}
+		if err != nil {
+			return err
+		}
+		if depth == 0 {
+			dec.off += int64(dec.buf.Len())
+			return nil
+		}
+	}
+}
+
+// nextHeader accounts for the bytes left in dec.buf and reads the header
+// of the next value into it.
+func (dec *Decoder) nextHeader() (header, error) {
+	dec.off += int64(dec.buf.Len())
+	dec.buf.Reset()
+	return dec.readHeader()
+}
+
+// innerHeader is like nextHeader for a value inside a List or Dict, where
+// the input must not end.
+func (dec *Decoder) innerHeader() (header, error) {
+	h, err := dec.nextHeader()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return h, err
+}
+
+// readHeader reads the header of the next value into dec.buf.
+func (dec *Decoder) readHeader() (header, error) {
+	br := dec.r.(io.ByteReader)
+	start := dec.buf.Len()
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return header{}, err
+		}
+		dec.buf.Grow(1)[0] = c
+		if Code(c)&NumSignBit == 0 {
+			break
+		}
+	}
+	h, err := readHeader(dec.buf.Bytes(), start)
+	if se, ok := err.(*SyntaxError); ok {
+		se.Offset += dec.off
+	}
+	return h, err
+}
+
+// readPayload reads n bytes into dec.buf. Large payloads are read in chunks
+// so that a corrupt length cannot make the decoder allocate more memory than
+// the input holds.
+func (dec *Decoder) readPayload(n uint64) error {
+	const chunk = 64 << 10
+	if n >= tooBig {
+		return syntaxErrorf(int(dec.off)+dec.buf.Len(), "payload of %d bytes is too big", n)
+	}
+	for n > 0 {
+		m := n
+		if m > chunk {
+			m = chunk
+		}
+		if _, err := io.ReadFull(dec.r, dec.buf.Grow(int(m))); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		n -= m
+	}
+	return nil
+}
+
+// discard skips n bytes of input.
+func (dec *Decoder) discard(n uint64) error {
+	var err error
+	var m int64
+	if d, ok := dec.r.(interface {
+		Discard(n int) (int, error)
+	}); ok && n < tooBig {
+		var k int
+		k, err = d.Discard(int(n))
+		m = int64(k)
+	} else {
+		m, err = io.CopyN(ioutil.Discard, dec.r, int64(n))
+	}
+	dec.off += m
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// decode decodes the value held in dec.buf and stores it in v.
+func (dec *Decoder) decode(v reflect.Value) {
+	if v.IsValid() && v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	d := decodeState{data: dec.buf.Bytes()}
+	dec.err = d.unmarshal(v)
+}
+
+// An UnmarshalTypeError describes a binpack value that was
+// not appropriate for a value of a specific Go type.
+type UnmarshalTypeError struct {
+	Value  string       // description of binpack value - "String", "List", "Integer -3"
+	Type   reflect.Type // type of Go value it could not be assigned to
+	Offset int64        // offset of the value in the input
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return "binpack: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+}
+
+// decodeState holds the input of a single Unmarshal or Decode call.
+type decodeState struct {
+	data  []byte
+	off   int // next read offset in data
+	opts  DecodeOptions
+	depth int // number of Lists and Dicts being decoded
+}
+
+// maxDepth is the deepest nesting of Lists and Dicts that is decoded.
+// Deeper input is rejected rather than exhausting the stack.
+const maxDepth = 10000
+
+// enter records that the List or Dict at start is being decoded.
+func (d *decodeState) enter(start int) {
+	d.depth++
+	if d.depth > maxDepth {
+		error_(syntaxErrorf(start, "exceeded max depth of %d", maxDepth))
+	}
+}
+
+// leave records that the innermost List or Dict has been decoded.
+func (d *decodeState) leave() {
+	d.depth--
+}
+
+// unmarshal decodes the value at d.off into v.
+func (d *decodeState) unmarshal(v reflect.Value) (err error) {
+	defer catchError(&err)
+	d.value(v)
+	return nil
+}
+
+// header reads the header of the next value.
+func (d *decodeState) header() header {
+	h, err := readHeader(d.data, d.off)
+	if err != nil {
+		error_(err)
+	}
+	d.off += h.size
+	return h
+}
+
+// payload returns the bytes following the header h.
+func (d *decodeState) payload(h header) []byte {
+	n := h.payload()
+	if n > uint64(len(d.data)-d.off) {
+		error_(io.ErrUnexpectedEOF)
+	}
+	end := d.off + int(n)
+	b := d.data[d.off:end:end]
+	d.off = end
+	return b
+}
+
+// closure consumes a Closure if it is the next byte and reports whether it did.
+func (d *decodeState) closure() bool {
+	if d.off >= len(d.data) {
+		error_(io.ErrUnexpectedEOF)
+	}
+	if Code(d.data[d.off]) == Closure {
+		d.off++
+		return true
+	}
+	return false
+}
+
+func (d *decodeState) typeError(what string, t reflect.Type, off int) {
+	error_(&UnmarshalTypeError{Value: what, Type: t, Offset: int64(off)})
+}
+
+// value decodes the next value into v. If v is the zero reflect.Value the
+// value is discarded.
+func (d *decodeState) value(v reflect.Value) {
+	start := d.off
+	if !v.IsValid() {
+		d.skip()
+		return
+	}
+	if v.Kind() != reflect.Ptr || !d.nilNext() {
+		if u, ok := unmarshaler(v); ok {
+			d.skip()
+			if err := u.UnmarshalBinpack(d.data[start:d.off]); err != nil {
+				error_(err)
+			}
+			return
+		}
+	}
+	h := d.header()
+	if h.code == Closure {
+		error_(syntaxErrorf(start, "unexpected Closure"))
+	}
+	if h.code == Nil {
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+	v = indirect(v)
+	if v.Kind() == reflect.Interface {
+		if v.NumMethod() != 0 {
+			d.typeError(h.code.String(), v.Type(), start)
+		}
+		v.Set(reflect.ValueOf(d.interfaceValue(h, start)))
+		return
+	}
+
+	switch h.code {
+	case True, False:
+		if v.Kind() != reflect.Bool {
+			d.typeError(h.code.String(), v.Type(), start)
+		}
+		v.SetBool(h.code == True)
+	case Integer:
+		d.integer(h, v, start)
+	case Float, Double:
+		f := d.float(h)
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			if v.OverflowFloat(f) {
+				d.typeError(h.code.String(), v.Type(), start)
+			}
+			v.SetFloat(f)
+		default:
+			d.typeError(h.code.String(), v.Type(), start)
+		}
+	case String, Blob:
+		b := d.payload(h)
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(d.str(b))
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			v.SetBytes(d.bytes(b))
+		case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+			n := reflect.Copy(v, reflect.ValueOf(b))
+			for ; n < v.Len(); n++ {
+				v.Index(n).SetUint(0)
+			}
+		default:
+			d.typeError(h.code.String(), v.Type(), start)
+		}
+	case List:
+		d.enter(start)
+		d.list(v, start)
+		d.leave()
+	case Dict:
+		d.enter(start)
+		d.dict(v, start)
+		d.leave()
+	}
+}
+
+// nilNext reports whether the next value is Nil.
+func (d *decodeState) nilNext() bool {
+	return d.off < len(d.data) && Code(d.data[d.off]) == Nil
+}
+
+// unmarshaler returns the Unmarshaler implemented by a pointer found in v,
+// allocating it if it is nil.
+func unmarshaler(v reflect.Value) (Unmarshaler, bool) {
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		v = v.Addr()
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.Type().Implements(unmarshalerType) {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return nil, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			u, ok := v.Interface().(Unmarshaler)
+			return u, ok
+		}
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	return nil, false
+}
+
+// indirect walks down v allocating pointers as needed,
+// until it gets to a non-pointer.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// skip skips over the next value.
+func (d *decodeState) skip() {
+	off, err := skipValue(d.data, d.off)
+	if err != nil {
+		error_(err)
+	}
+	d.off = off
+}
+
+func (d *decodeState) integer(h header, v reflect.Value, start int) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := h.int64()
+		if !ok || v.OverflowInt(i) {
+			d.typeError(h.describe(), v.Type(), start)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if h.neg() || v.OverflowUint(h.n) {
+			d.typeError(h.describe(), v.Type(), start)
+		}
+		v.SetUint(h.n)
+	case reflect.Float32, reflect.Float64:
+		f := float64(h.n)
+		if h.neg() {
+			f = -f
+		}
+		v.SetFloat(f)
+	default:
+		d.typeError(h.describe(), v.Type(), start)
+	}
+}
+
+// float decodes the payload of a Float or Double.
+func (d *decodeState) float(h header) float64 {
+	b := d.payload(h)
+	if h.code == Float {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+// str returns the string held in b, sharing its memory if the options allow it.
+func (d *decodeState) str(b []byte) string {
+	if d.opts.UnsafeStrings {
+		return bytesToString(b)
+	}
+	return string(b)
+}
+
+// bytes returns the blob held in b, copying it unless the options allow aliasing.
+func (d *decodeState) bytes(b []byte) []byte {
+	if d.opts.AliasBlobs {
+		return b
+	}
+	return append([]byte{}, b...)
+}
+
+// bytesToString returns a string that shares its memory with b.
+func bytesToString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+func (d *decodeState) list(v reflect.Value, start int) {
+	switch v.Kind() {
+	case reflect.Slice:
+		i := 0
+		for ; !d.closure(); i++ {
+			if i >= v.Cap() {
+				newcap := v.Cap() + v.Cap()/2
+				if newcap < 4 {
+					newcap = 4
+				}
+				newv := reflect.MakeSlice(v.Type(), v.Len(), newcap)
+				reflect.Copy(newv, v)
+				v.Set(newv)
+			}
+			if i >= v.Len() {
+				v.SetLen(i + 1)
+			}
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+			d.value(v.Index(i))
+		}
+		if i < v.Len() {
+			v.SetLen(i)
+		}
+		if i == 0 && v.IsNil() {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		}
+	case reflect.Array:
+		i := 0
+		for ; !d.closure(); i++ {
+			if i < v.Len() {
+				d.value(v.Index(i))
+			} else {
+				d.value(reflect.Value{})
+			}
+		}
+		for ; i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+	default:
+		d.typeError("List", v.Type(), start)
+	}
+}
+
+func (d *decodeState) dict(v reflect.Value, start int) {
+	if v.Kind() == reflect.Struct {
+		d.structFields(v)
+		return
+	}
+	if v.Kind() != reflect.Map {
+		d.typeError("Dict", v.Type(), start)
+	}
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+	for !d.closure() {
+		key := reflect.New(t.Key()).Elem()
+		d.key(key)
+		elem := reflect.New(t.Elem()).Elem()
+		d.value(elem)
+		v.SetMapIndex(key, elem)
+	}
+}
+
+// structFields decodes the entries of a Dict into the fields of the struct
+// v, skipping the entries that match no field.
+func (d *decodeState) structFields(v reflect.Value) {
+	fields := structs.Fields(v.Type())
+	for !d.closure() {
+		start := d.off
+		var f *structs.Field
+		if h := d.header(); h.code == String {
+			f = fieldByName(fields, d.payload(h))
+		} else {
+			d.off = start
+			d.skip()
+		}
+		if f == nil {
+			d.skip()
+			continue
+		}
+		d.value(v.FieldByIndex(f.Index))
+	}
+}
+
+// key decodes a Dict key into v, interning string keys if a KeyCache is set.
+func (d *decodeState) key(v reflect.Value) {
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		if k := d.interfaceKey(); k != nil {
+			v.Set(reflect.ValueOf(k))
+		}
+		return
+	}
+	if d.opts.Keys != nil && v.Kind() == reflect.String {
+		start := d.off
+		h := d.header()
+		if h.code == String || h.code == Blob {
+			v.SetString(d.opts.Keys.intern(d.payload(h)))
+			return
+		}
+		d.off = start
+	}
+	d.value(v)
+}
+
+// interfaceValue decodes the value whose header is h into its default Go
+// representation:
+//
+//	nil for Nil
+//	bool for True and False
+//	int64 for Integer, or uint64 if it does not fit an int64
+//	float32 for Float and float64 for Double
+//	string for String and []byte for Blob
+//	[]interface{} for List
+//	map[interface{}]interface{} for Dict
+func (d *decodeState) interfaceValue(h header, start int) interface{} {
+	switch h.code {
+	case True, False:
+		return h.code == True
+	case Integer:
+		if !h.neg() && h.n > math.MaxInt64 {
+			return h.n
+		}
+		i, ok := h.int64()
+		if !ok {
+			errorf("%s overflows int64", h.describe())
+		}
+		return i
+	case Float:
+		return float32(d.float(h))
+	case Double:
+		return d.float(h)
+	case String:
+		return d.str(d.payload(h))
+	case Blob:
+		return d.bytes(d.payload(h))
+	case List:
+		d.enter(start)
+		l := []interface{}{}
+		for !d.closure() {
+			off := d.off
+			l = append(l, d.interfaceValue(d.header(), off))
+		}
+		d.leave()
+		return l
+	case Dict:
+		d.enter(start)
+		m := map[interface{}]interface{}{}
+		for !d.closure() {
+			k := d.interfaceKey()
+			off := d.off
+			m[k] = d.interfaceValue(d.header(), off)
+		}
+		d.leave()
+		return m
+	case Closure:
+		error_(syntaxErrorf(start, "unexpected Closure"))
+	}
+	return nil
+}
+
+// interfaceKey decodes a Dict key for a map[interface{}]interface{}.
+// Blob keys become strings, since slices cannot be map keys.
+func (d *decodeState) interfaceKey() interface{} {
+	start := d.off
+	h := d.header()
+	switch h.code {
+	case String, Blob:
+		b := d.payload(h)
+		if d.opts.Keys != nil {
+			return d.opts.Keys.intern(b)
+		}
+		return d.str(b)
+	case List, Dict:
+		error_(syntaxErrorf(start, "%v cannot be used as a Dict key", h.code))
+	}
+	return d.interfaceValue(h, start)
 }
@@ -0,0 +1,99 @@
+package binpack
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestDecoder_Stream(t *testing.T) {
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	in := []interface{}{"first", []int{1, 2, 3}, map[string]bool{"ok": true}, 7}
+	for _, v := range in {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("binpack:Encode error %v", err)
+		}
+	}
+
+	dec := NewDecoder(&w)
+	var s string
+	var l []int
+	var m map[string]bool
+	for _, ptr := range []interface{}{&s, &l, &m} {
+		if err := dec.Decode(ptr); err != nil {
+			t.Fatalf("binpack:Decode error %v", err)
+		}
+	}
+	if s != "first" || !reflect.DeepEqual(l, []int{1, 2, 3}) || !m["ok"] {
+		t.Fatalf("binpack:Decode got %q, %v, %v", s, l, m)
+	}
+	var i int
+	if err := dec.DecodeValue(reflect.ValueOf(&i).Elem()); err != nil || i != 7 {
+		t.Fatalf("binpack:DecodeValue got %v, %v; wanted %v", i, err, 7)
+	}
+	if err := dec.Decode(&i); err != io.EOF {
+		t.Fatalf("binpack:Decode expected EOF: got %v", err)
+	}
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	testCases := []struct {
+		in   []byte
+		want error
+	}{
+		{[]byte{0x02, 0x41}, io.ErrUnexpectedEOF},
+		{[]byte{0x85}, io.ErrUnexpectedEOF},
+		{[]byte{0x25, 'a'}, io.ErrUnexpectedEOF},
+		{[]byte{0x06, 0, 0}, io.ErrUnexpectedEOF},
+	}
+	for _, test := range testCases {
+		var v interface{}
+		err := NewDecoder(bytes.NewReader(test.in)).Decode(&v)
+		if err != test.want {
+			t.Fatalf("binpack:Decode(% x) expected %v: got %v", test.in, test.want, err)
+		}
+	}
+
+	dec := NewDecoder(bytes.NewReader([]byte{0x41, 0x01}))
+	if err := dec.Decode(nil); err != nil {
+		t.Fatalf("binpack:Decode error %v", err)
+	}
+	err := dec.Decode(nil)
+	if se, ok := err.(*SyntaxError); !ok || se.Offset != 1 {
+		t.Fatalf("binpack:Decode expected *SyntaxError at offset 1: got %v", err)
+	}
+
+	if err := NewDecoder(bytes.NewReader([]byte{0x41})).Decode(1); err == nil {
+		t.Fatal("binpack:Decode into a non-pointer expected error: got none")
+	}
+}
+
+func TestDecoder_Skip(t *testing.T) {
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	_ = enc.Encode(map[string][]interface{}{"skip": {1, "me", bytes.Repeat([]byte{1}, 300)}})
+	_ = enc.Encode("skipped too")
+	_ = enc.Encode(42)
+
+	dec := NewDecoder(&w)
+	if err := dec.Skip(); err != nil {
+		t.Fatalf("binpack:Skip error %v", err)
+	}
+	if err := dec.Decode(nil); err != nil {
+		t.Fatalf("binpack:Decode(nil) error %v", err)
+	}
+	var i int
+	if err := dec.Decode(&i); err != nil || i != 42 {
+		t.Fatalf("binpack:Decode after Skip got %v, %v; wanted %v", i, err, 42)
+	}
+	if err := dec.Skip(); err != io.EOF {
+		t.Fatalf("binpack:Skip expected EOF: got %v", err)
+	}
+
+	dec = NewDecoder(bytes.NewReader([]byte{0x02, 0x25, 'a'}))
+	if err := dec.Skip(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("binpack:Skip expected %v: got %v", io.ErrUnexpectedEOF, err)
+	}
+}
@@ -1,5 +1,10 @@
 package binpack
 
+import (
+	"io"
+	"net"
+)
+
 // tooBig provides a sanity check for sizes; used in several places. Upper limit
 // of is 1GB on 32-bit systems, 8GB on 64-bit, allowing room to grow a little
 // without overflow.
@@ -7,13 +12,21 @@ const tooBig = (1 << 30) << (^uint(0) >> 62)
 
 // encBuffer is an extremely simple, fast implementation of a write-only byte buffer.
 // It never returns a non-nil error, but Write returns an error value so it matches io.Writer.
+//
+// Besides copying into data, the buffer can hold references to caller owned
+// slices (see WriteRef). These are kept in segs, interleaved with the parts
+// of data written before them, and are sent with vectored I/O by WriteTo.
 type encBuffer struct {
 	data []byte
 	buf  [64]byte
+	segs [][]byte // data[:mark] and referenced slices, in write order
+	mark int      // start of the part of data that is not in segs yet
+	refs int      // number of referenced bytes in segs
 }
 
-func (e *encBuffer) WriteByte(c byte) {
+func (e *encBuffer) WriteByte(c byte) error {
 	e.data = append(e.data, c)
+	return nil
 }
 
 func (e *encBuffer) WriteCode(c Code) {
@@ -29,15 +42,75 @@ func (e *encBuffer) WriteString(s string) {
 	e.data = append(e.data, s...)
 }
 
+// WriteRef appends p to the buffer without copying it. The caller must not
+// modify p until the buffer has been reset.
+func (e *encBuffer) WriteRef(p []byte) {
+	if len(e.data) > e.mark {
+		e.segs = append(e.segs, e.data[e.mark:len(e.data):len(e.data)])
+	}
+	e.segs = append(e.segs, p)
+	e.mark = len(e.data)
+	e.refs += len(p)
+}
+
 func (e *encBuffer) Len() int {
-	return len(e.data)
+	return len(e.data) + e.refs
 }
 
+// Bytes returns the buffered data. If the buffer holds referenced slices they
+// are copied into a new contiguous slice.
 func (e *encBuffer) Bytes() []byte {
-	return e.data
+	if len(e.segs) == 0 {
+		return e.data
+	}
+	b := make([]byte, 0, e.Len())
+	for _, seg := range e.segs {
+		b = append(b, seg...)
+	}
+	return append(b, e.data[e.mark:]...)
+}
+
+// WriteTo writes the buffered data to w. Referenced slices are written
+// together with the copied data through net.Buffers, which uses writev on
+// connections that support it.
+func (e *encBuffer) WriteTo(w io.Writer) (int64, error) {
+	if len(e.segs) == 0 {
+		n, err := w.Write(e.data)
+		return int64(n), err
+	}
+	bufs := net.Buffers(append(e.segs, e.data[e.mark:]))
+	return bufs.WriteTo(w)
+}
+
+// A bufMark is a position in an encBuffer to truncate it back to.
+type bufMark struct {
+	data, segs, mark, refs int
+}
+
+// Mark returns the current end of the buffer.
+func (e *encBuffer) Mark() bufMark {
+	return bufMark{len(e.data), len(e.segs), e.mark, e.refs}
+}
+
+// Truncate discards what was written since m was taken. The buffer must
+// not have been reset in between.
+func (e *encBuffer) Truncate(m bufMark) {
+	for i := m.segs; i < len(e.segs); i++ {
+		e.segs[i] = nil
+	}
+	e.data = e.data[:m.data]
+	e.segs = e.segs[:m.segs]
+	e.mark = m.mark
+	e.refs = m.refs
 }
 
 func (e *encBuffer) Reset() {
+	for i := range e.segs {
+		e.segs[i] = nil // drop references to caller memory
+	}
+	e.segs = e.segs[0:0]
+	e.mark = 0
+	e.refs = 0
 	if len(e.data) >= tooBig {
 		e.data = e.buf[0:0]
 	} else {
@@ -61,3 +61,29 @@ func TestEncBuffer_Reset(t *testing.T) {
 		t.Fatalf("encBuffer:Reset got %v; wanted %v", out, []byte{})
 	}
 }
+
+func TestEncBuffer_WriteRef(t *testing.T) {
+	eb := encBuffer{}
+	eb.WriteString("ab")
+	ref := []byte("cd")
+	eb.WriteRef(ref)
+	eb.WriteString("e")
+	if eb.Len() != 5 {
+		t.Fatalf("encBuffer:Len got %v; wanted %v", eb.Len(), 5)
+	}
+	if out := eb.Bytes(); !bytes.Equal(out, []byte("abcde")) {
+		t.Fatalf("encBuffer:Bytes got %q; wanted %q", out, "abcde")
+	}
+	var w bytes.Buffer
+	n, err := eb.WriteTo(&w)
+	if err != nil {
+		t.Fatal("encBuffer:WriteTo error", err)
+	}
+	if n != 5 || w.String() != "abcde" {
+		t.Fatalf("encBuffer:WriteTo wrote %q (%v bytes); wanted %q", w.String(), n, "abcde")
+	}
+	eb.Reset()
+	if eb.Len() != 0 || len(eb.segs) != 0 {
+		t.Fatalf("encBuffer:Reset left %v bytes in %v segments", eb.Len(), len(eb.segs))
+	}
+}
@@ -5,15 +5,21 @@ import (
 	"io"
 	"math"
 	"reflect"
+
+	"github.com/theodesp/binpack/internal/structs"
 )
 
 // An Encoder manages the transmission of type and data information to the
 // other side of a connection. It is NOT safe for concurrent use by multiple
 // goroutines.
 type Encoder struct {
-	w   io.Writer // the writer to write to
-	buf encBuffer // buffer to use when encoding data
-	err error
+	w         io.Writer // the writer to write to
+	buf       encBuffer // buffer to use when encoding data
+	threshold int       // flush buf to w once it holds this many bytes; 0 disables streaming
+	zeroCopy  int       // reference blobs of at least this many bytes instead of copying; 0 disables
+	flushes   int       // number of times buf was written out
+	failed    error     // error of a value written out in part; returned by every later call
+	err       error
 }
 
 // NewEncoder returns a new encoder that will transmit on the io.Writer.
@@ -30,22 +36,93 @@ func (enc *Encoder) Encode(e interface{}) error {
 
 // EncodeValue transmits the data item represented by the reflection value,
 func (enc *Encoder) EncodeValue(value reflect.Value) error {
+	if enc.failed != nil {
+		return enc.failed
+	}
 	enc.err = nil
-	enc.buf.Reset()
+	if enc.threshold == 0 {
+		enc.buf.Reset()
+	}
+	mark, flushes := enc.buf.Mark(), enc.flushes
 	// Encode the object.
 	enc.encode(value)
+	if enc.err != nil && enc.threshold != 0 {
+		if enc.flushes == flushes {
+			// Drop the part of the value that was encoded.
+			enc.buf.Truncate(mark)
+		} else {
+			// Part of the value has already been written out, so the
+			// stream cannot be continued.
+			enc.failed = enc.err
+		}
+	}
 	if enc.err == nil {
+		if enc.threshold == 0 {
+			enc.writeTo(enc.w)
+		} else {
+			enc.flushIfFull()
+		}
+	}
+	return enc.err
+}
+
+// SetFlushThreshold puts the encoder in streaming mode. Instead of building
+// a whole value in memory before writing it, the encoder writes its buffer
+// to the underlying io.Writer whenever it holds at least n bytes, including
+// while it is still walking a large List, Dict or Blob. In streaming mode
+// data may stay buffered after Encode returns, so callers must call Flush
+// once they are done. A value that fails to encode is dropped from the
+// buffer, unless part of it has already been written, after which every
+// call returns its error. A value of n <= 0 restores the default behaviour
+// of writing each value as a whole.
+func (enc *Encoder) SetFlushThreshold(n int) {
+	if n < 0 {
+		n = 0
+	}
+	if n == 0 && enc.threshold > 0 {
+		_ = enc.Flush()
+	}
+	enc.threshold = n
+}
+
+// SetZeroCopyThreshold makes the encoder keep a reference to every Blob of
+// at least n bytes instead of copying it into its buffer. The length prefix
+// and the caller's slice are then written as separate segments using
+// net.Buffers, so a TCP or Unix connection sends them with a single writev.
+// The caller must not modify such a slice until Encode returns, or until
+// Flush in streaming mode. A value of n <= 0 disables zero-copy writes.
+func (enc *Encoder) SetZeroCopyThreshold(n int) {
+	if n < 0 {
+		n = 0
+	}
+	enc.zeroCopy = n
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (enc *Encoder) Flush() error {
+	if enc.failed != nil {
+		return enc.failed
+	}
+	if enc.buf.Len() > 0 {
 		enc.writeTo(enc.w)
 	}
 	return enc.err
 }
 
+// flushIfFull writes the buffered data once it passes the flush threshold.
+func (enc *Encoder) flushIfFull() {
+	if enc.threshold > 0 && enc.buf.Len() >= enc.threshold {
+		enc.writeTo(enc.w)
+	}
+}
+
 // writeTo sends the data item to the writer
 func (enc *Encoder) writeTo(w io.Writer) {
 	// Write the data.
-	_, err := w.Write(enc.buf.Bytes())
+	_, err := enc.buf.WriteTo(w)
 	// Drain the buffer and restore the space.
 	enc.buf.Reset()
+	enc.flushes++
 	if err != nil {
 		enc.setError(err)
 	}
@@ -62,6 +139,10 @@ func (enc *Encoder) encode(v reflect.Value) {
 	if v.Kind() == reflect.Ptr && v.IsNil() {
 		panic("binpack: cannot encode nil pointer of type " + v.Type().String())
 	}
+	if m, ok := marshaler(v); ok {
+		enc.encodeMarshaler(v.Type(), m)
+		return
+	}
 	switch v.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16,
 		reflect.Int32, reflect.Int64:
@@ -100,11 +181,44 @@ func (enc *Encoder) encode(v reflect.Value) {
 		enc.encodeList(v)
 	case reflect.Map:
 		enc.encodeMap(v)
+	case reflect.Struct:
+		enc.encodeStruct(v)
+	case reflect.Ptr, reflect.Interface:
+		enc.encode(v.Elem())
 	default:
 		enc.encodeString(fmt.Sprintf("binpack: Unsupported type %s", v.Type()))
 	}
 }
 
+// marshaler returns the Marshaler implemented by v or, if v is addressable,
+// by a pointer to v.
+func marshaler(v reflect.Value) (Marshaler, bool) {
+	if !v.IsValid() {
+		return nil, false
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(v.Type()).Implements(marshalerType) {
+		v = v.Addr()
+	}
+	if !v.Type().Implements(marshalerType) || !v.CanInterface() {
+		return nil, false
+	}
+	m, ok := v.Interface().(Marshaler)
+	return m, ok
+}
+
+// encodeMarshaler writes the encoding returned by m, which must be exactly
+// one value.
+func (enc *Encoder) encodeMarshaler(t reflect.Type, m Marshaler) {
+	b, err := m.MarshalBinpack()
+	if err != nil {
+		error_(err)
+	}
+	if n, err := Next(b); err != nil || n != len(b) {
+		errorf("MarshalBinpack of type %s returned invalid data", t)
+	}
+	_, _ = enc.buf.Write(b)
+}
+
 // encode nil into one byte to buffer.
 //
 // +-----------+
@@ -172,6 +286,24 @@ func (enc *Encoder) encodeLen(n int, code Code) {
 // +----------------+
 func (enc *Encoder) encodeBlob(b []byte) {
 	enc.encodeLen(len(b), Blob)
+	if enc.zeroCopy > 0 && len(b) >= enc.zeroCopy {
+		enc.buf.WriteRef(b)
+		return
+	}
+	// In streaming mode copy large blobs in threshold sized chunks so the
+	// buffer never grows much past the threshold.
+	for enc.threshold > 0 && enc.buf.Len()+len(b) > enc.threshold {
+		n := enc.threshold - enc.buf.Len()
+		if n < 0 {
+			n = 0
+		}
+		_, _ = enc.buf.Write(b[:n])
+		b = b[n:]
+		enc.writeTo(enc.w)
+		if enc.err != nil {
+			return
+		}
+	}
 	_, _ = enc.buf.Write(b)
 }
 
@@ -244,7 +376,9 @@ func (enc *Encoder) encodeList(v reflect.Value) {
 	l := v.Len()
 	enc.buf.WriteCode(List)
 	for i := 0; i < l; i++ {
-		if err := enc.EncodeValue(v.Index(i)); err != nil {
+		enc.encode(v.Index(i))
+		enc.flushIfFull()
+		if enc.err != nil {
 			return
 		}
 	}
@@ -287,10 +421,33 @@ func (enc *Encoder) encodeMap(v reflect.Value) {
 	enc.buf.WriteCode(Dict)
 
 	for _, key := range v.MapKeys() {
-		if err := enc.EncodeValue(key); err != nil {
+		enc.encode(key)
+		enc.encode(v.MapIndex(key))
+		enc.flushIfFull()
+		if enc.err != nil {
 			return
 		}
-		if err := enc.EncodeValue(v.MapIndex(key)); err != nil {
+	}
+	enc.buf.WriteCode(Closure)
+}
+
+// A struct is encoded as a Dict with a String key for every field, as
+// described on Marshal. A nil pointer field is encoded as Nil.
+func (enc *Encoder) encodeStruct(v reflect.Value) {
+	enc.buf.WriteCode(Dict)
+	for _, f := range structs.Fields(v.Type()) {
+		fv := v.FieldByIndex(f.Index)
+		if f.OmitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		enc.encodeString(f.Name)
+		if fv.Kind() == reflect.Ptr && fv.IsNil() {
+			enc.encodeNil()
+		} else {
+			enc.encode(fv)
+		}
+		enc.flushIfFull()
+		if enc.err != nil {
 			return
 		}
 	}
@@ -335,17 +492,12 @@ func (enc *Encoder) encodeInt(v reflect.Value) {
 	}
 
 	val := v.Int()
+	mag := uint64(val)
 	if val < 0 {
-		val = -val
+		mag = uint64(-val) // also right for math.MinInt64
 		tag |= IntegerNegative
 	}
-
-	for val > int64(TagPackInteger) || val>>3 > 0 {
-		enc.buf.WriteCode(NumSignBit | (Code(val) & NumMask))
-		val >>= 7
-	}
-
-	enc.buf.WriteCode(tag | Code(val))
+	enc.encodeInteger(tag, mag)
 }
 
 func (enc *Encoder) encodeUInt(v reflect.Value) {
@@ -361,7 +513,12 @@ func (enc *Encoder) encodeUInt(v reflect.Value) {
 		tag |= IntegerTypeLong
 	}
 
-	val := v.Uint()
+	enc.encodeInteger(tag, v.Uint())
+}
+
+// encodeInteger writes the magnitude val of an Integer followed by the
+// last byte, which carries tag: the type, subtype and sign information.
+func (enc *Encoder) encodeInteger(tag Code, val uint64) {
 	for val > uint64(TagPackInteger) || val>>3 > 0 {
 		enc.buf.WriteCode(NumSignBit | (Code(val) & NumMask))
 		val >>= 7
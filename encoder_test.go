@@ -56,12 +56,12 @@ func TestEncoder(t *testing.T) {
 		{float64(3.14), "061f85eb51b81e0940"},
 		{float64(0), "060000000000000000"},
 		{float64(-3.14), "061f85eb51b81e09c0"},
-		{[]string{"a", "b", "c"}, "21612162216301"},
-		{[3][2]int{}, "40400140400140400101"},
-		{[2][3]string{}, "202020012020200101"},
-		{[3]string{"a", "b", "c"}, "21612162216301"},
+		{[]string{"a", "b", "c"}, "0221612162216301"},
+		{[3][2]int{}, "0202404001024040010240400101"},
+		{[2][3]string{}, "020220202001022020200101"},
+		{[3]string{"a", "b", "c"}, "0221612162216301"},
 		{map[string]string(nil), "0301"},
-		{map[int]string{1: "string"}, "4126737472696e6701"},
+		{map[int]string{1: "string"}, "034126737472696e6701"},
 		//{
 		//	map[string]string{"a": "", "b": "", "c": "", "d": "", "e": ""},
 		//	"21612021622021632021642021652001",
@@ -69,9 +69,11 @@ func TestEncoder(t *testing.T) {
 		{int8(-1), "69"},
 		{int32(1), "59"},
 		{int64(math.MaxInt64), "ffffffffffffffffff40"},
+		{int64(math.MinInt64), "80808080808080808061"},
 		{uint8(8), "8848"},
 		{uint64(math.MaxUint64), "ffffffffffffffffff41"},
-		{struct{}{}, "a32062696e7061636b3a20556e737570706f72746564207479706520737472756374207b7d"},
+		{struct{}{}, "0301"},
+		{[]interface{}{nil, 1}, "020f4101"},
 	}
 	var w bytes.Buffer
 	enc := NewEncoder(&w)
@@ -88,3 +90,161 @@ func TestEncoder(t *testing.T) {
 		}
 	}
 }
+
+// countingWriter records the size of every Write call.
+type countingWriter struct {
+	bytes.Buffer
+	writes []int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.writes = append(cw.writes, len(p))
+	return cw.Buffer.Write(p)
+}
+
+func TestEncoder_FlushThreshold(t *testing.T) {
+	in := make([]string, 1000)
+	for i := range in {
+		in[i] = "element"
+	}
+	var want bytes.Buffer
+	if err := NewEncoder(&want).Encode(in); err != nil {
+		t.Fatalf("binpack:Encode error %v", err)
+	}
+
+	var w countingWriter
+	enc := NewEncoder(&w)
+	enc.SetFlushThreshold(64)
+	if err := enc.Encode(in); err != nil {
+		t.Fatalf("binpack:Encode error %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("binpack:Flush error %v", err)
+	}
+	if !bytes.Equal(w.Bytes(), want.Bytes()) {
+		t.Fatalf("streamed encoding differs from buffered encoding")
+	}
+	for _, n := range w.writes {
+		if n > 64+8 {
+			t.Fatalf("binpack:Encode wrote %v bytes at once; wanted at most %v", n, 64+8)
+		}
+	}
+}
+
+func TestEncoder_FlushThresholdBlob(t *testing.T) {
+	blob := bytes.Repeat([]byte{0xab}, 1000)
+	var want bytes.Buffer
+	if err := NewEncoder(&want).Encode(blob); err != nil {
+		t.Fatalf("binpack:Encode error %v", err)
+	}
+
+	var w countingWriter
+	enc := NewEncoder(&w)
+	enc.SetFlushThreshold(100)
+	if err := enc.Encode(blob); err != nil {
+		t.Fatalf("binpack:Encode error %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("binpack:Flush error %v", err)
+	}
+	if !bytes.Equal(w.Bytes(), want.Bytes()) {
+		t.Fatalf("streamed encoding differs from buffered encoding")
+	}
+	for _, n := range w.writes {
+		if n > 100 {
+			t.Fatalf("binpack:Encode wrote %v bytes at once; wanted at most %v", n, 100)
+		}
+	}
+}
+
+func TestEncoder_FlushThresholdError(t *testing.T) {
+	enc := NewEncoder(errorWriter{})
+	enc.SetFlushThreshold(8)
+	if err := enc.Encode([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}); err == nil {
+		t.Fatal("expected error from writer got none")
+	}
+	if err := enc.Encode(1); err == nil {
+		t.Fatal("expected error after a partly written value got none")
+	}
+	if err := enc.Flush(); err == nil {
+		t.Fatal("expected error after a partly written value on Flush got none")
+	}
+}
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalBinpack() ([]byte, error) {
+	return nil, errors.New("forced error")
+}
+
+func TestEncoder_FlushThresholdRollback(t *testing.T) {
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	enc.SetFlushThreshold(1024)
+	enc.SetZeroCopyThreshold(1)
+	if err := enc.Encode(1); err != nil {
+		t.Fatalf("binpack:Encode error %v", err)
+	}
+	if err := enc.Encode([]interface{}{1, []byte{2}, failingMarshaler{}}); err == nil {
+		t.Fatal("binpack:Encode of a failing Marshaler expected error: got none")
+	}
+	if err := enc.Encode(5); err != nil {
+		t.Fatalf("binpack:Encode error %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("binpack:Flush error %v", err)
+	}
+	if got := hex.EncodeToString(w.Bytes()); got != "4145" {
+		t.Fatalf("binpack:Encode after a failed value wrote %s; wanted 4145", got)
+	}
+
+	enc.SetFlushThreshold(2)
+	if err := enc.Encode([]int{1, 2, 3}); err != nil {
+		t.Fatalf("binpack:Encode error %v", err)
+	}
+	if err := enc.Encode([]interface{}{1, 2, 3, failingMarshaler{}}); err == nil {
+		t.Fatal("binpack:Encode of a failing Marshaler expected error: got none")
+	}
+	if err := enc.Encode(5); err == nil {
+		t.Fatal("binpack:Encode after a partly written value expected error: got none")
+	}
+}
+
+// recordingWriter keeps the slices passed to Write without copying them.
+type recordingWriter struct {
+	writes [][]byte
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	rw.writes = append(rw.writes, p)
+	return len(p), nil
+}
+
+func TestEncoder_ZeroCopyBlob(t *testing.T) {
+	blob := bytes.Repeat([]byte{0xcd}, 4096)
+	in := [][]byte{[]byte("small"), blob}
+	var want bytes.Buffer
+	if err := NewEncoder(&want).Encode(in); err != nil {
+		t.Fatalf("binpack:Encode error %v", err)
+	}
+
+	var w recordingWriter
+	enc := NewEncoder(&w)
+	enc.SetZeroCopyThreshold(1024)
+	if err := enc.Encode(in); err != nil {
+		t.Fatalf("binpack:Encode error %v", err)
+	}
+	got := bytes.Join(w.writes, nil)
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Fatalf("zero-copy encoding differs from copied encoding")
+	}
+	var shared bool
+	for _, p := range w.writes {
+		if len(p) == len(blob) && &p[0] == &blob[0] {
+			shared = true
+		}
+	}
+	if !shared {
+		t.Fatal("binpack:Encode copied a blob above the zero-copy threshold")
+	}
+}
@@ -0,0 +1,55 @@
+// Package structs holds the rules mapping struct fields to Dict entries,
+// shared by the binpack encoder and decoder and by binpack/schema.
+package structs
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A Field is a struct field encoded by binpack.Marshal.
+type Field struct {
+	Name      string // Dict key of the field
+	Index     []int  // index sequence for reflect.Type.FieldByIndex
+	OmitEmpty bool   // whether the field is left out when empty
+}
+
+var fieldCache sync.Map // map[reflect.Type][]Field
+
+// Fields returns the fields of the struct type t that binpack.Marshal
+// encodes, in declaration order. The slice is shared and must not be
+// modified.
+//
+// Exported fields are encoded as Dict entries keyed by the field name, or
+// by the name given in a `binpack:"name"` tag. The option "omitempty", as
+// in `binpack:"name,omitempty"`, leaves out a field holding its zero value,
+// or an empty slice or map. The tag "-" leaves out the field. Embedded
+// structs are encoded like any other field.
+func Fields(t reflect.Type) []Field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]Field)
+	}
+	var fields []Field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		tag := sf.Tag.Get("binpack")
+		if tag == "-" {
+			continue
+		}
+		f := Field{Name: sf.Name, Index: sf.Index}
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			f.OmitEmpty = strings.Contains(tag[i:], ",omitempty")
+			tag = tag[:i]
+		}
+		if tag != "" {
+			f.Name = tag
+		}
+		fields = append(fields, f)
+	}
+	f, _ := fieldCache.LoadOrStore(t, fields)
+	return f.([]Field)
+}
@@ -0,0 +1,35 @@
+package structs
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testPerson struct {
+	Name    string   `binpack:"name"`
+	Age     int8     `binpack:"age,omitempty"`
+	Email   *string  `binpack:"email"`
+	Tags    []string `binpack:",omitempty"`
+	Secret  string   `binpack:"-"`
+	private int
+	Extra   interface{}
+}
+
+func TestFields(t *testing.T) {
+	want := []Field{
+		{Name: "name", Index: []int{0}},
+		{Name: "age", Index: []int{1}, OmitEmpty: true},
+		{Name: "email", Index: []int{2}},
+		{Name: "Tags", Index: []int{3}, OmitEmpty: true},
+		{Name: "Extra", Index: []int{6}},
+	}
+	for i := 0; i < 2; i++ { // the second call is served from the cache
+		got := Fields(reflect.TypeOf(testPerson{}))
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("structs:Fields got %+v; wanted %+v", got, want)
+		}
+	}
+	if got := Fields(reflect.TypeOf(struct{}{})); len(got) != 0 {
+		t.Fatalf("structs:Fields of struct{} got %+v; wanted none", got)
+	}
+}
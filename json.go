@@ -0,0 +1,458 @@
+package binpack
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// BlobFormat selects how Blobs are written as JSON.
+type BlobFormat int
+
+const (
+	// BlobBase64 writes a Blob as a base64 string. FromJSON reads such
+	// strings back as Strings.
+	BlobBase64 BlobFormat = iota
+	// BlobTagged writes a Blob as an object with a single "$blob" member
+	// holding the base64 string, which FromJSON reads back as a Blob.
+	BlobTagged
+)
+
+// BigIntFormat selects how Integers beyond 2^53, which JavaScript and many
+// JSON parsers cannot hold exactly, are written as JSON.
+type BigIntFormat int
+
+const (
+	// BigIntNumber writes every Integer as a number with all its digits.
+	BigIntNumber BigIntFormat = iota
+	// BigIntString writes Integers beyond 2^53 as strings of digits.
+	BigIntString
+	// BigIntError makes ToJSON fail on Integers beyond 2^53.
+	BigIntError
+)
+
+// maxSafeInt is the largest integer magnitude a float64 holds exactly.
+const maxSafeInt = 1 << 53
+
+// JSONOptions configures FromJSON and ToJSON. The zero value writes Blobs
+// as base64 strings and Integers as numbers.
+type JSONOptions struct {
+	Blobs   BlobFormat
+	BigInts BigIntFormat
+
+	// Float32Precision writes Floats with the shortest digits that read back
+	// as the same float32, so that 0.1 stays 0.1 instead of showing the
+	// float64 value 0.10000000149011612 of the float32 nearest to 0.1.
+	Float32Precision bool
+}
+
+// FromJSON reads a stream of JSON values from r and writes their binpack
+// encodings to w, token by token, without holding whole values in memory.
+// Objects become Dicts with String keys, arrays become Lists, integer
+// numbers that fit 64 bits become Long Integers and other numbers become
+// Doubles.
+func FromJSON(r io.Reader, w io.Writer) error {
+	return JSONOptions{}.FromJSON(r, w)
+}
+
+// ToJSON reads a stream of binpack values from r and writes them to w as
+// JSON, one value per line, without holding whole values in memory. Float
+// and Double values are written with a fraction or an exponent so that
+// FromJSON reads them back as Doubles. Dict keys that are not Strings are
+// written as the JSON text of the key, and Lists or Dicts used as keys are
+// an error, as are NaN and infinite numbers. Strings that are not valid
+// UTF-8 have their invalid bytes replaced with U+FFFD.
+func ToJSON(r io.Reader, w io.Writer) error {
+	return JSONOptions{}.ToJSON(r, w)
+}
+
+// FromJSON is like the package level FromJSON, using the options.
+func (o JSONOptions) FromJSON(r io.Reader, w io.Writer) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	enc := NewEncoder(w)
+	enc.SetFlushThreshold(4096)
+	j := jsonReader{dec: dec, enc: enc, opts: o}
+	for {
+		tok, err := j.token()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = j.value(tok)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return enc.Flush()
+}
+
+// jsonReader holds the state of FromJSON.
+type jsonReader struct {
+	dec    *json.Decoder
+	enc    *Encoder
+	opts   JSONOptions
+	peeked []json.Token
+}
+
+func (j *jsonReader) token() (json.Token, error) {
+	if n := len(j.peeked); n > 0 {
+		tok := j.peeked[n-1]
+		j.peeked = j.peeked[:n-1]
+		return tok, nil
+	}
+	return j.dec.Token()
+}
+
+// innerToken is like token for a token inside an array or object, where
+// the input must not end.
+func (j *jsonReader) innerToken() (json.Token, error) {
+	tok, err := j.token()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return tok, err
+}
+
+// unread makes tok the next token.
+func (j *jsonReader) unread(tok json.Token) {
+	j.peeked = append(j.peeked, tok)
+}
+
+// value writes the value starting with tok.
+func (j *jsonReader) value(tok json.Token) error {
+	defer j.enc.flushIfFull()
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			return j.array()
+		}
+		return j.object()
+	case string:
+		j.enc.encodeString(tok)
+	case json.Number:
+		return j.number(string(tok))
+	case bool:
+		j.enc.encodeBool(tok)
+	case nil:
+		j.enc.encodeNil()
+	}
+	return nil
+}
+
+func (j *jsonReader) array() error {
+	j.enc.buf.WriteCode(List)
+	for {
+		tok, err := j.innerToken()
+		if err != nil {
+			return err
+		}
+		if tok == json.Delim(']') {
+			break
+		}
+		if err := j.value(tok); err != nil {
+			return err
+		}
+	}
+	j.enc.buf.WriteCode(Closure)
+	return nil
+}
+
+func (j *jsonReader) object() error {
+	if j.opts.Blobs == BlobTagged {
+		if ok, err := j.taggedBlob(); ok || err != nil {
+			return err
+		}
+	}
+	j.enc.buf.WriteCode(Dict)
+	for {
+		tok, err := j.innerToken()
+		if err != nil {
+			return err
+		}
+		if tok == json.Delim('}') {
+			break
+		}
+		j.enc.encodeString(tok.(string))
+		if tok, err = j.innerToken(); err != nil {
+			return err
+		}
+		if err := j.value(tok); err != nil {
+			return err
+		}
+	}
+	j.enc.buf.WriteCode(Closure)
+	return nil
+}
+
+// taggedBlob reads an object holding only a "$blob" string, whose start has
+// been read, as a Blob. If the object is anything else the tokens read are
+// put back and taggedBlob reports false.
+func (j *jsonReader) taggedBlob() (bool, error) {
+	var toks []json.Token
+	for len(toks) < 3 {
+		tok, err := j.innerToken()
+		if err != nil {
+			return false, err
+		}
+		toks = append(toks, tok)
+		if len(toks) == 1 && tok != "$blob" {
+			break
+		}
+		if _, ok := tok.(string); len(toks) == 2 && !ok {
+			break
+		}
+	}
+	if len(toks) == 3 && toks[2] == json.Delim('}') {
+		b, err := base64.StdEncoding.DecodeString(toks[1].(string))
+		if err != nil {
+			return false, errors.New("binpack: invalid base64 in $blob: " + err.Error())
+		}
+		j.enc.encodeBlob(b)
+		return true, nil
+	}
+	for i := len(toks) - 1; i >= 0; i-- {
+		j.unread(toks[i])
+	}
+	return false, nil
+}
+
+func (j *jsonReader) number(s string) error {
+	if !strings.ContainsAny(s, ".eE") {
+		digits := strings.TrimPrefix(s, "-")
+		if n, err := strconv.ParseUint(digits, 10, 64); err == nil {
+			tag := Integer
+			if len(digits) < len(s) {
+				tag |= IntegerNegative
+			}
+			j.enc.encodeInteger(tag, n)
+			return nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return errors.New("binpack: JSON number " + s + " overflows a Double")
+	}
+	j.enc.encodeFloat64(f)
+	return nil
+}
+
+// ToJSON is like the package level ToJSON, using the options.
+func (o JSONOptions) ToJSON(r io.Reader, w io.Writer) error {
+	j := jsonWriter{dec: NewDecoder(r), w: bufio.NewWriter(w), opts: o}
+	for {
+		h, err := j.dec.nextHeader()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = j.value(h)
+		}
+		if err != nil {
+			return err
+		}
+		j.w.WriteByte('\n')
+	}
+	return j.w.Flush()
+}
+
+// jsonWriter holds the state of ToJSON.
+type jsonWriter struct {
+	dec   *Decoder
+	w     *bufio.Writer
+	opts  JSONOptions
+	depth int // number of Lists and Dicts being written
+}
+
+// value writes the value whose header h has just been read.
+func (j *jsonWriter) value(h header) error {
+	if h.code == List || h.code == Dict {
+		if j.depth++; j.depth > maxDepth {
+			return syntaxErrorf(int(j.dec.off), "exceeded max depth of %d", maxDepth)
+		}
+		defer func() { j.depth-- }()
+	}
+	switch h.code {
+	case List:
+		j.w.WriteByte('[')
+		for i := 0; ; i++ {
+			h, err := j.dec.innerHeader()
+			if err != nil {
+				return err
+			}
+			if h.code == Closure {
+				break
+			}
+			if i > 0 {
+				j.w.WriteByte(',')
+			}
+			if err := j.value(h); err != nil {
+				return err
+			}
+		}
+		j.w.WriteByte(']')
+	case Dict:
+		j.w.WriteByte('{')
+		for i := 0; ; i++ {
+			h, err := j.dec.innerHeader()
+			if err != nil {
+				return err
+			}
+			if h.code == Closure {
+				break
+			}
+			if i > 0 {
+				j.w.WriteByte(',')
+			}
+			if err := j.key(h); err != nil {
+				return err
+			}
+			j.w.WriteByte(':')
+			if h, err = j.dec.innerHeader(); err != nil {
+				return err
+			}
+			if h.code == Closure {
+				return syntaxErrorf(int(j.dec.off), "Dict with a key but no value")
+			}
+			if err := j.value(h); err != nil {
+				return err
+			}
+		}
+		j.w.WriteByte('}')
+	case Closure:
+		return syntaxErrorf(int(j.dec.off), "unexpected Closure")
+	case Blob:
+		if err := j.dec.readPayload(h.n); err != nil {
+			return err
+		}
+		b := base64.StdEncoding.EncodeToString(j.dec.buf.Bytes()[h.size:])
+		if j.opts.Blobs == BlobTagged {
+			j.w.WriteString(`{"$blob":"` + b + `"}`)
+		} else {
+			j.w.WriteString(`"` + b + `"`)
+		}
+	case Integer:
+		if h.n > maxSafeInt && j.opts.BigInts != BigIntNumber {
+			if j.opts.BigInts == BigIntError {
+				return errors.New("binpack: " + h.describe() + " is too big for JSON")
+			}
+			j.w.WriteString(`"` + j.scalar(h) + `"`)
+			break
+		}
+		j.w.WriteString(j.scalar(h))
+	default:
+		if err := j.dec.readPayload(h.payload()); err != nil {
+			return err
+		}
+		if h.code == String {
+			writeJSONString(j.w, j.dec.buf.Bytes()[h.size:])
+			break
+		}
+		s := j.scalar(h)
+		if s == "" {
+			return errors.New("binpack: " + h.code.String() + " is not a JSON number")
+		}
+		j.w.WriteString(s)
+	}
+	return nil
+}
+
+// key writes the Dict key whose header h has just been read.
+func (j *jsonWriter) key(h header) error {
+	switch h.code {
+	case List, Dict:
+		return errors.New("binpack: " + h.code.String() + " used as a Dict key cannot be written as JSON")
+	case String, Blob:
+		if err := j.dec.readPayload(h.n); err != nil {
+			return err
+		}
+		b := j.dec.buf.Bytes()[h.size:]
+		if h.code == Blob {
+			b = []byte(base64.StdEncoding.EncodeToString(b))
+		}
+		writeJSONString(j.w, b)
+		return nil
+	}
+	if err := j.dec.readPayload(h.payload()); err != nil {
+		return err
+	}
+	s := j.scalar(h)
+	if s == "" {
+		return errors.New("binpack: " + h.code.String() + " key is not a JSON number")
+	}
+	j.w.WriteString(`"` + s + `"`)
+	return nil
+}
+
+// scalar returns the JSON text of a Nil, True, False, Integer, Float or
+// Double whose payload is in j.dec.buf, or "" for NaN and infinities.
+func (j *jsonWriter) scalar(h header) string {
+	switch h.code {
+	case Nil:
+		return "null"
+	case True:
+		return "true"
+	case False:
+		return "false"
+	case Integer:
+		if h.neg() {
+			return "-" + strconv.FormatUint(h.n, 10)
+		}
+		return strconv.FormatUint(h.n, 10)
+	}
+	p := j.dec.buf.Bytes()[h.size:]
+	var f float64
+	bitSize := 64
+	if h.code == Float {
+		f = float64(math.Float32frombits(binary.LittleEndian.Uint32(p)))
+		if j.opts.Float32Precision {
+			bitSize = 32
+		}
+	} else {
+		f = math.Float64frombits(binary.LittleEndian.Uint64(p))
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return ""
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// writeJSONString writes b as a JSON string.
+func writeJSONString(w *bufio.Writer, b []byte) {
+	const hexDigits = "0123456789abcdef"
+	w.WriteByte('"')
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		switch {
+		case r == '"' || r == '\\':
+			w.WriteByte('\\')
+			w.WriteByte(byte(r))
+		case r == '\n':
+			w.WriteString(`\n`)
+		case r == '\r':
+			w.WriteString(`\r`)
+		case r == '\t':
+			w.WriteString(`\t`)
+		case r < 0x20:
+			w.WriteString(`\u00`)
+			w.WriteByte(hexDigits[r>>4])
+			w.WriteByte(hexDigits[r&0xf])
+		default:
+			w.WriteRune(r)
+		}
+		b = b[size:]
+	}
+	w.WriteByte('"')
+}
@@ -0,0 +1,134 @@
+package binpack
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestFromJSON(t *testing.T) {
+	testCases := []struct {
+		in   string
+		opts JSONOptions
+		want string
+	}{
+		{`null true false`, JSONOptions{}, "nil\ntrue\nfalse"},
+		{`0 -5 18446744073709551615 -18446744073709551615`, JSONOptions{}, "0\n-5\n18446744073709551615\n-18446744073709551615"},
+		{`18446744073709551616 1.5 1e3 -0.0`, JSONOptions{}, "1.8446744073709552e+19\n1.5\n1000.0\n-0.0"},
+		{`"aé\n"`, JSONOptions{}, `"aé\n"`},
+		{`{"a": [1, {"b": []}], "c": {}}`, JSONOptions{}, `{"a": [1, {"b": []}], "c": {}}`},
+		{`{"$blob": "AQI="}`, JSONOptions{}, `{"$blob": "AQI="}`},
+		{`{"$blob": "AQI="}`, JSONOptions{Blobs: BlobTagged}, "h'0102'"},
+		{`[{"$blob": ""}, {"$blob": 1}, {"$blob": "AQI=", "x": 2}, {"$blob": [1]}, {"x": 1}]`, JSONOptions{Blobs: BlobTagged},
+			`[h'', {"$blob": 1}, {"$blob": "AQI=", "x": 2}, {"$blob": [1]}, {"x": 1}]`},
+	}
+	for _, test := range testCases {
+		var out bytes.Buffer
+		if err := test.opts.FromJSON(strings.NewReader(test.in), &out); err != nil {
+			t.Fatalf("binpack:FromJSON(%s) error %v", test.in, err)
+		}
+		if got := Format(out.Bytes()); got != test.want {
+			t.Fatalf("binpack:FromJSON(%s) got %s; wanted %s", test.in, got, test.want)
+		}
+	}
+
+	errCases := []struct {
+		in        string
+		truncated bool
+	}{
+		{`[1,`, true},
+		{`{"a": 1`, true},
+		{`{"$blob"`, true},
+		{`{"a" 1}`, false},
+		{`1e999`, false},
+		{`{"$blob": "!"}`, false},
+	}
+	for _, test := range errCases {
+		var out bytes.Buffer
+		err := (JSONOptions{Blobs: BlobTagged}).FromJSON(strings.NewReader(test.in), &out)
+		if test.truncated && err != io.ErrUnexpectedEOF {
+			t.Fatalf("binpack:FromJSON(%s) got error %v; wanted %v", test.in, err, io.ErrUnexpectedEOF)
+		}
+		if err == nil || err == io.EOF {
+			t.Fatalf("binpack:FromJSON(%s) got error %v; wanted a failure", test.in, err)
+		}
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	testCases := []struct {
+		in   string
+		opts JSONOptions
+		want string
+	}{
+		{"nil true false", JSONOptions{}, "null\ntrue\nfalse\n"},
+		{"5i8 -5 1.0 1.5 0.1f 1e+21", JSONOptions{}, "5\n-5\n1.0\n1.5\n0.10000000149011612\n1e+21\n"},
+		{"0.1f 3f", JSONOptions{Float32Precision: true}, "0.1\n3.0\n"},
+		{`"a\"\\\x01\t\xffé"`, JSONOptions{}, `"a\"\\\u0001\t` + "�é\"\n"},
+		{"h'0102'", JSONOptions{}, "\"AQI=\"\n"},
+		{"h'0102'", JSONOptions{Blobs: BlobTagged}, "{\"$blob\":\"AQI=\"}\n"},
+		{`[1, {"a": [], 2: nil, -3i8: 1.5, true: {}, 2.5f: h'', h'01': 0}]`, JSONOptions{},
+			`[1,{"a":[],"2":null,"-3":1.5,"true":{},"2.5":"","AQ==":0}]` + "\n"},
+		{"9007199254740992 9007199254740993 -9007199254740993", JSONOptions{}, "9007199254740992\n9007199254740993\n-9007199254740993\n"},
+		{"9007199254740992 9007199254740993 -9007199254740993", JSONOptions{BigInts: BigIntString}, "9007199254740992\n\"9007199254740993\"\n\"-9007199254740993\"\n"},
+	}
+	for _, test := range testCases {
+		data, err := Parse(test.in)
+		if err != nil {
+			t.Fatalf("binpack:Parse(%s) error %v", test.in, err)
+		}
+		var out bytes.Buffer
+		if err := test.opts.ToJSON(bytes.NewReader(data), &out); err != nil {
+			t.Fatalf("binpack:ToJSON(%s) error %v", test.in, err)
+		}
+		if out.String() != test.want {
+			t.Fatalf("binpack:ToJSON(%s) got %q; wanted %q", test.in, out.String(), test.want)
+		}
+	}
+
+	errCases := []struct {
+		in   string
+		opts JSONOptions
+	}{
+		{"NaN", JSONOptions{}},
+		{"-Infinityf", JSONOptions{}},
+		{"{[1]: 2}", JSONOptions{}},
+		{"9007199254740993", JSONOptions{BigInts: BigIntError}},
+	}
+	for _, test := range errCases {
+		data, _ := Parse(test.in)
+		var out bytes.Buffer
+		if err := test.opts.ToJSON(bytes.NewReader(data), &out); err == nil {
+			t.Fatalf("binpack:ToJSON(%s) expected error: got none", test.in)
+		}
+	}
+	for _, in := range [][]byte{{0x02, 0x41}, {0x01}, {0x03, 0x41, 0x01}, {0x25}} {
+		var out bytes.Buffer
+		if err := ToJSON(bytes.NewReader(in), &out); err == nil {
+			t.Fatalf("binpack:ToJSON(%x) expected error: got none", in)
+		}
+	}
+
+	deep := bytes.Repeat([]byte{byte(List)}, 1<<20)
+	err := ToJSON(bytes.NewReader(deep), ioutil.Discard)
+	if serr, ok := err.(*SyntaxError); !ok || serr.Offset != maxDepth {
+		t.Fatalf("binpack:ToJSON of deeply nested Lists got %v; wanted a SyntaxError at %d", err, maxDepth)
+	}
+}
+
+func TestJSON_RoundTrip(t *testing.T) {
+	in := `{"id":12345678901234567890,"tags":["a","b"],"blob":{"$blob":"AAEC"},"ratio":0.25,"neg":-1,"nested":{"ok":true,"none":null,"list":[[],{}]}}` + "\n"
+	opts := JSONOptions{Blobs: BlobTagged}
+	var data, out bytes.Buffer
+	if err := opts.FromJSON(strings.NewReader(in), &data); err != nil {
+		t.Fatalf("binpack:FromJSON error %v", err)
+	}
+	if err := opts.ToJSON(&data, &out); err != nil {
+		t.Fatalf("binpack:ToJSON error %v", err)
+	}
+	if out.String() != in {
+		t.Fatalf("binpack:ToJSON(FromJSON(%s)) got %s", in, out.String())
+	}
+}
@@ -0,0 +1,158 @@
+package binpack
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Marshaler is the interface implemented by types that can marshal
+// themselves into a single valid binpack value.
+type Marshaler interface {
+	MarshalBinpack() ([]byte, error)
+}
+
+// Unmarshaler is the interface implemented by types that can unmarshal a
+// binpack encoding of themselves. The input is exactly one encoded value.
+// UnmarshalBinpack must copy the data if it wishes to retain it after
+// returning.
+type Unmarshaler interface {
+	UnmarshalBinpack([]byte) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+// RawMessage is a raw encoded binpack value. It implements Marshaler and
+// Unmarshaler and can be used to delay decoding or to precompute an encoding.
+type RawMessage []byte
+
+// MarshalBinpack returns m as the binpack encoding of m. A nil RawMessage
+// encodes as Nil.
+func (m RawMessage) MarshalBinpack() ([]byte, error) {
+	if m == nil {
+		return []byte{byte(Nil)}, nil
+	}
+	return m, nil
+}
+
+// UnmarshalBinpack sets *m to a copy of data.
+func (m *RawMessage) UnmarshalBinpack(data []byte) error {
+	if m == nil {
+		return errors.New("binpack: UnmarshalBinpack on nil pointer")
+	}
+	*m = append((*m)[0:0], data...)
+	return nil
+}
+
+// Marshal returns the binpack encoding of v.
+//
+// If v implements Marshaler, Marshal calls its MarshalBinpack method.
+// Otherwise values are encoded as described on the Encoder methods.
+//
+// Structs are encoded as Dicts with a String key for every exported field.
+// The key is the field name unless the field's tag gives another:
+//
+//	Name  string `binpack:"name"`           // key "name"
+//	Email string `binpack:"email,omitempty"` // left out if empty
+//	Token string `binpack:"-"`               // never encoded
+//
+// The "omitempty" option leaves out false, 0, a nil pointer or interface
+// value, and an empty string, array, slice or map. A nil pointer field is
+// otherwise encoded as Nil.
+func Marshal(v interface{}) ([]byte, error) {
+	enc := new(Encoder)
+	enc.encode(reflect.ValueOf(v))
+	if enc.err != nil {
+		return nil, enc.err
+	}
+	return enc.buf.Bytes(), nil
+}
+
+// Unmarshal decodes the binpack-encoded data and stores the result
+// in the value pointed to by v. Strings and blobs are copied out of data.
+// Use DecodeOptions to decode without copying.
+//
+// If a value implements Unmarshaler, Unmarshal calls its UnmarshalBinpack
+// method with the encoding of that value, unless it is Nil and the value is
+// a pointer, which is set to nil instead.
+//
+// A Dict is decoded into a struct by storing the value of every String key
+// in the field with that key, as given by Marshal, preferring an exact
+// match but accepting a case-insensitive one. Entries with other keys are
+// ignored.
+func Unmarshal(data []byte, v interface{}) error {
+	return DecodeOptions{}.Unmarshal(data, v)
+}
+
+// DecodeOptions configures how Unmarshal stores decoded values. The zero
+// value copies every String and Blob out of the input.
+type DecodeOptions struct {
+	// AliasBlobs makes decoded []byte values point into the input slice
+	// instead of holding a copy. The input must not be modified while such
+	// values are in use.
+	AliasBlobs bool
+
+	// UnsafeStrings makes decoded strings share memory with the input slice.
+	// Go strings are expected to be immutable: modifying or reusing the input
+	// after Unmarshal returns silently changes every such string, so only use
+	// this when the input outlives the decoded values and is never written to.
+	UnsafeStrings bool
+
+	// Keys, when not nil, interns Dict keys decoded into strings, so that a key
+	// repeated across many records is allocated only once. Interned keys never
+	// share memory with the input.
+	Keys *KeyCache
+}
+
+// Unmarshal decodes data like the package level Unmarshal, using the options.
+func (o DecodeOptions) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("binpack: Unmarshal into a non-pointer or nil pointer")
+	}
+	d := decodeState{data: data, opts: o}
+	if err := d.unmarshal(rv.Elem()); err != nil {
+		return err
+	}
+	if d.off < len(data) {
+		return syntaxErrorf(d.off, "trailing data after value")
+	}
+	return nil
+}
+
+// A KeyCache interns the Dict keys seen by Unmarshal. It can be shared by
+// many calls so that records with the same keys share their strings.
+// The zero value is an empty cache without a size limit. A KeyCache is NOT
+// safe for concurrent use by multiple goroutines.
+type KeyCache struct {
+	keys map[string]string
+	max  int
+}
+
+// NewKeyCache returns a cache that holds at most max keys. Keys seen after
+// the cache is full are still decoded, but not interned. A value of
+// max <= 0 means no limit.
+func NewKeyCache(max int) *KeyCache {
+	return &KeyCache{keys: make(map[string]string), max: max}
+}
+
+// Len returns the number of interned keys.
+func (c *KeyCache) Len() int {
+	return len(c.keys)
+}
+
+func (c *KeyCache) intern(b []byte) string {
+	if s, ok := c.keys[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	if c.keys == nil {
+		c.keys = make(map[string]string)
+	}
+	if c.max <= 0 || len(c.keys) < c.max {
+		c.keys[s] = s
+	}
+	return s
+}
@@ -0,0 +1,271 @@
+package binpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	testCases := []struct {
+		in   string
+		ptr  interface{}
+		want interface{}
+	}{
+		{"04", new(bool), true},
+		{"05", new(bool), false},
+		{"69", new(int8), int8(-1)},
+		{"59", new(int32), int32(1)},
+		{"8848", new(uint8), uint8(8)},
+		{"ffffffffffffffffff40", new(int64), int64(math.MaxInt64)},
+		{"ffffffffffffffffff41", new(uint64), uint64(math.MaxUint64)},
+		{"8060", new(int64), int64(math.MinInt64 + 1<<63)},
+		{"41", new(float64), float64(1)},
+		{"07c3f54840", new(float32), float32(3.14)},
+		{"061f85eb51b81e0940", new(float64), 3.14},
+		{"2568656c6c6f", new(string), "hello"},
+		{"15616263c2a2", new([]byte), []byte("abc¢")},
+		{"13010203", new([3]byte), [3]byte{1, 2, 3}},
+		{"2161", new([]byte), []byte("a")},
+		{"0221612162216301", new([]string), []string{"a", "b", "c"}},
+		{"02216121622163216401", new([2]string), [2]string{"a", "b"}},
+		{"0201", new([]int), []int{}},
+		{"034126737472696e6701", new(map[int]string), map[int]string{1: "string"}},
+		{"0f", new(*int), (*int)(nil)},
+		{"42", new(*int), func() *int { i := 2; return &i }()},
+		{"0f", new(string), ""},
+	}
+	for _, test := range testCases {
+		data, _ := hex.DecodeString(test.in)
+		if err := Unmarshal(data, test.ptr); err != nil {
+			t.Fatalf("binpack:Unmarshal(%s) error %v", test.in, err)
+		}
+		got := reflect.ValueOf(test.ptr).Elem().Interface()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("binpack:Unmarshal(%s) got %#v; wanted %#v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestUnmarshal_Interface(t *testing.T) {
+	in := []interface{}{
+		nil, true, int64(-7), uint64(math.MaxUint64), float32(1.5), 2.5,
+		"str", []byte{1, 2},
+		map[interface{}]interface{}{"k": []interface{}{int64(1)}, int64(2): "two"},
+	}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("binpack:Marshal error %v", err)
+	}
+	var out interface{}
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("binpack:Unmarshal error %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("binpack:Unmarshal got %#v; wanted %#v", out, in)
+	}
+}
+
+func TestUnmarshal_Errors(t *testing.T) {
+	testCases := []struct {
+		in  string
+		ptr interface{}
+	}{
+		{"", new(int)},
+		{"02", new([]int)},
+		{"2261", new(string)},
+		{"01", new(int)},
+		{"08", new(int)},
+		{"30", new(string)},
+		{"850f", new(int)},
+		{"4141", new(int)},
+		{"0341", new(map[int]int)},
+		{"034101", new(map[int]int)},
+		{"2161", new(int)},
+		{"61", new(uint)},
+		{"c849", new(int8)},
+		{"0201", new(map[int]int)},
+		{"ffffffffffffffffff43", new(uint64)},
+	}
+	for _, test := range testCases {
+		data, _ := hex.DecodeString(test.in)
+		if err := Unmarshal(data, test.ptr); err == nil {
+			t.Fatalf("binpack:Unmarshal(%s) into %T expected error: got none", test.in, test.ptr)
+		}
+	}
+	if err := Unmarshal([]byte{0x41}, 1); err == nil {
+		t.Fatal("binpack:Unmarshal into a non-pointer expected error: got none")
+	}
+	deep := bytes.Repeat([]byte{byte(List)}, 1<<20)
+	for _, ptr := range []interface{}{new(interface{}), new([]interface{})} {
+		if err := Unmarshal(deep, ptr); err == nil {
+			t.Fatalf("binpack:Unmarshal of deeply nested Lists into %T expected error: got none", ptr)
+		}
+	}
+}
+
+func TestUnmarshal_TypeError(t *testing.T) {
+	var i int
+	err := Unmarshal([]byte{0x02, 0x41, 0x01}, &i)
+	te, ok := err.(*UnmarshalTypeError)
+	if !ok {
+		t.Fatalf("binpack:Unmarshal expected *UnmarshalTypeError: got %v", err)
+	}
+	if te.Value != "List" || te.Type != reflect.TypeOf(i) || te.Offset != 0 {
+		t.Fatalf("binpack:Unmarshal got %+v", te)
+	}
+	if err = Unmarshal([]byte{0x02, 0x01, 0x01}, new([]int)); err == nil {
+		t.Fatal("binpack:Unmarshal expected error for trailing data: got none")
+	}
+	if se, ok := err.(*SyntaxError); !ok || se.Offset != 2 {
+		t.Fatalf("binpack:Unmarshal expected *SyntaxError at offset 2: got %v", err)
+	}
+	if err = Unmarshal([]byte{0x02, 0x41}, new([]int)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("binpack:Unmarshal expected %v: got %v", io.ErrUnexpectedEOF, err)
+	}
+}
+
+type testAddress struct {
+	City string `binpack:"city"`
+}
+
+type testPerson struct {
+	Name    string       `binpack:"name"`
+	Age     int8         `binpack:"age,omitempty"`
+	Email   *string      `binpack:"email"`
+	Home    *testAddress `binpack:"home,omitempty"`
+	Tags    []string     `binpack:"tags,omitempty"`
+	Secret  string       `binpack:"-"`
+	private int
+	Extra   interface{}
+}
+
+func TestMarshal_Struct(t *testing.T) {
+	email := "a@b"
+	testCases := []struct {
+		in   interface{}
+		want string
+	}{
+		{testPerson{Name: "ann", Secret: "x", private: 1}, `{"name": "ann", "email": nil, "Extra": nil}`},
+		{
+			&testPerson{Name: "ann", Age: 31, Email: &email, Home: &testAddress{"X"}, Tags: []string{"t"}, Extra: 1.5},
+			`{"name": "ann", "age": 31i8, "email": "a@b", "home": {"city": "X"}, "tags": ["t"], "Extra": 1.5}`,
+		},
+		{[]testAddress{{"X"}}, `[{"city": "X"}]`},
+	}
+	for _, test := range testCases {
+		data, err := Marshal(test.in)
+		if err != nil {
+			t.Fatalf("binpack:Marshal(%+v) error %v", test.in, err)
+		}
+		if got := Format(data); got != test.want {
+			t.Fatalf("binpack:Marshal(%+v) got %s; wanted %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestUnmarshal_Struct(t *testing.T) {
+	data, err := Parse(`{"NAME": "bob", "age": 5i8, "email": nil, "home": {"city": "Y", "zip": 1},` +
+		` 5: 1, "unknown": [1], "Secret": "s", "extra": 2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := "old"
+	p := testPerson{Email: &email, Tags: []string{"old"}}
+	if err := Unmarshal(data, &p); err != nil {
+		t.Fatalf("binpack:Unmarshal error %v", err)
+	}
+	want := testPerson{Name: "bob", Age: 5, Home: &testAddress{"Y"}, Tags: []string{"old"}, Extra: int64(2)}
+	if !reflect.DeepEqual(p, want) {
+		t.Fatalf("binpack:Unmarshal got %+v; wanted %+v", p, want)
+	}
+
+	data, _ = Parse(`{"age": "x"}`)
+	if err := Unmarshal(data, &p); err == nil {
+		t.Fatal("binpack:Unmarshal of a String into an int8 field expected error: got none")
+	}
+	data, _ = Parse(`[1]`)
+	if _, ok := Unmarshal(data, &p).(*UnmarshalTypeError); !ok {
+		t.Fatal("binpack:Unmarshal of a List into a struct expected *UnmarshalTypeError")
+	}
+}
+
+func TestDecodeOptions_Alias(t *testing.T) {
+	data, _ := Marshal([]interface{}{"name", []byte("blob")})
+	var out []interface{}
+	opts := DecodeOptions{AliasBlobs: true, UnsafeStrings: true}
+	if err := opts.Unmarshal(data, &out); err != nil {
+		t.Fatalf("binpack:Unmarshal error %v", err)
+	}
+	s, b := out[0].(string), out[1].([]byte)
+	if s != "name" || string(b) != "blob" {
+		t.Fatalf("binpack:Unmarshal got %#v", out)
+	}
+	// Both values share memory with data, so changing data changes them.
+	copy(data[bytes.Index(data, []byte("name")):], "NAME")
+	copy(data[bytes.Index(data, []byte("blob")):], "BLOB")
+	if s != "NAME" || string(b) != "BLOB" {
+		t.Fatalf("binpack:Unmarshal did not alias the input: got %q, %q", s, b)
+	}
+	if cap(b) != len(b) {
+		t.Fatalf("binpack:Unmarshal aliased blob has capacity %v; wanted %v", cap(b), len(b))
+	}
+
+	data, _ = Marshal([]byte("copy"))
+	if err := Unmarshal(data, &b); err != nil {
+		t.Fatalf("binpack:Unmarshal error %v", err)
+	}
+	data[1] = 'C'
+	if string(b) != "copy" {
+		t.Fatalf("binpack:Unmarshal without options aliased the input: got %q", b)
+	}
+}
+
+func TestDecodeOptions_Keys(t *testing.T) {
+	cache := NewKeyCache(0)
+	opts := DecodeOptions{Keys: cache}
+	var records []map[string]int
+	for i := 0; i < 3; i++ {
+		data, _ := Marshal(map[string]int{"id": i, "size": i * 2})
+		var m map[string]int
+		if err := opts.Unmarshal(data, &m); err != nil {
+			t.Fatalf("binpack:Unmarshal error %v", err)
+		}
+		records = append(records, m)
+	}
+	if cache.Len() != 2 {
+		t.Fatalf("KeyCache:Len got %v; wanted %v", cache.Len(), 2)
+	}
+	if records[2]["size"] != 4 {
+		t.Fatalf("binpack:Unmarshal got %v", records[2])
+	}
+
+	limited := NewKeyCache(1)
+	var m map[interface{}]interface{}
+	data, _ := Marshal(map[string]int{"a": 1, "b": 2})
+	if err := (DecodeOptions{Keys: limited}).Unmarshal(data, &m); err != nil {
+		t.Fatalf("binpack:Unmarshal error %v", err)
+	}
+	if limited.Len() != 1 || len(m) != 2 {
+		t.Fatalf("KeyCache with limit 1 holds %v keys and decoded %v", limited.Len(), m)
+	}
+}
+
+func BenchmarkUnmarshal_Options(b *testing.B) {
+	in := make([]map[string][]byte, 100)
+	for i := range in {
+		in[i] = map[string][]byte{"key": bytes.Repeat([]byte{'x'}, 256)}
+	}
+	data, _ := Marshal(in)
+	opts := DecodeOptions{AliasBlobs: true, Keys: NewKeyCache(0)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var out []map[string][]byte
+		if err := opts.Unmarshal(data, &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package binpack
+
+// MergePatch applies patch to target following the merge patch rules of
+// RFC 7386, and returns the encoding of the result. When patch is a Dict,
+// each of its entries replaces the entry of target with an equal key,
+// merging recursively when both values are Dicts, and an entry whose value
+// is Nil deletes the entry instead. Entries that are new to target are
+// added at the end. Any other patch replaces target as a whole.
+func MergePatch(target, patch []byte) ([]byte, error) {
+	var t, p Value
+	if err := t.UnmarshalBinpack(target); err != nil {
+		return nil, err
+	}
+	if err := p.UnmarshalBinpack(patch); err != nil {
+		return nil, err
+	}
+	return mergePatch(t, p).MarshalBinpack()
+}
+
+// CreateMergePatch returns a patch that turns original into modified when
+// applied with MergePatch. As with JSON merge patches, a Nil value in a
+// modified Dict cannot be expressed, because it reads as a deletion, and
+// List elements are never patched individually.
+func CreateMergePatch(original, modified []byte) ([]byte, error) {
+	var o, m Value
+	if err := o.UnmarshalBinpack(original); err != nil {
+		return nil, err
+	}
+	if err := m.UnmarshalBinpack(modified); err != nil {
+		return nil, err
+	}
+	return createMergePatch(o, m).MarshalBinpack()
+}
+
+func mergePatch(target, patch Value) Value {
+	if patch.Kind() != Dict {
+		return patch
+	}
+	var dict []Entry
+	if target.Kind() == Dict {
+		dict = append(dict, target.dict...)
+	}
+	for _, e := range patch.dict {
+		i := entryIndex(dict, e.Key)
+		switch {
+		case e.Value.Kind() == Nil:
+			if i >= 0 {
+				dict = append(dict[:i:i], dict[i+1:]...)
+			}
+		case i >= 0:
+			dict[i].Value = mergePatch(dict[i].Value, e.Value)
+		default:
+			dict = append(dict, Entry{Key: e.Key, Value: mergePatch(Value{}, e.Value)})
+		}
+	}
+	if dict == nil {
+		dict = []Entry{}
+	}
+	return NewDict(dict...)
+}
+
+// entryIndex returns the index of the first entry of dict whose key equals
+// k, or -1.
+func entryIndex(dict []Entry, k Value) int {
+	for i, e := range dict {
+		if (CompareOptions{}).equal(e.Key, k) {
+			return i
+		}
+	}
+	return -1
+}
+
+func createMergePatch(original, modified Value) Value {
+	if original.Kind() != Dict || modified.Kind() != Dict {
+		return modified
+	}
+	var o CompareOptions
+	patch := []Entry{}
+	mkeys := newKeyIndex(modified)
+	for _, e := range original.dict {
+		if mkeys.find(o, e.Key) < 0 {
+			patch = append(patch, Entry{Key: e.Key, Value: NewNil()})
+		}
+	}
+	okeys := newKeyIndex(original)
+	for _, e := range modified.dict {
+		i := okeys.find(o, e.Key)
+		switch {
+		case i < 0:
+			patch = append(patch, e)
+		case !o.equal(original.dict[i].Value, e.Value):
+			patch = append(patch, Entry{Key: e.Key, Value: createMergePatch(original.dict[i].Value, e.Value)})
+		}
+	}
+	return NewDict(patch...)
+}
@@ -0,0 +1,74 @@
+package binpack
+
+import (
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	type M = map[string]interface{}
+	testCases := []struct {
+		target, patch, want interface{}
+	}{
+		{M{"a": "b"}, M{"a": "c"}, M{"a": "c"}},
+		{M{"a": "b"}, M{"b": "c"}, M{"a": "b", "b": "c"}},
+		{M{"a": "b"}, M{"a": nil}, M{}},
+		{M{"a": "b", "b": "c"}, M{"a": nil}, M{"b": "c"}},
+		{M{"a": []string{"b"}}, M{"a": "c"}, M{"a": "c"}},
+		{M{"a": "c"}, M{"a": []string{"b"}}, M{"a": []string{"b"}}},
+		{M{"a": M{"b": "c"}}, M{"a": M{"b": "d", "c": nil}}, M{"a": M{"b": "d"}}},
+		{M{"a": []M{{"b": "c"}}}, M{"a": []int{1}}, M{"a": []int{1}}},
+		{[]string{"a", "b"}, []string{"c", "d"}, []string{"c", "d"}},
+		{M{"a": "b"}, []string{"c"}, []string{"c"}},
+		{M{"a": "foo"}, nil, nil},
+		{M{"a": "foo"}, "bar", "bar"},
+		{M{"e": nil}, M{"a": 1}, M{"e": nil, "a": 1}},
+		{[]int{1, 2}, M{"a": "b", "c": nil}, M{"a": "b"}},
+		{M{}, M{"a": M{"bb": M{"ccc": nil}}}, M{"a": M{"bb": M{}}}},
+		{map[int]string{1: "a", 2: "b"}, map[int]interface{}{1: nil, 3: "c"}, map[int]string{2: "b", 3: "c"}},
+	}
+	for _, test := range testCases {
+		target, _ := Marshal(test.target)
+		patch, _ := Marshal(test.patch)
+		want, _ := Marshal(test.want)
+		got, err := MergePatch(target, patch)
+		if err != nil {
+			t.Fatalf("binpack:MergePatch(%v, %v) error %v", test.target, test.patch, err)
+		}
+		if !Equal(got, want) {
+			t.Fatalf("binpack:MergePatch(%v, %v) got %x; wanted %x", test.target, test.patch, got, want)
+		}
+	}
+	if _, err := MergePatch([]byte{0x02}, []byte{0x0f}); err == nil {
+		t.Fatal("binpack:MergePatch of malformed data expected error: got none")
+	}
+}
+
+func TestCreateMergePatch(t *testing.T) {
+	type M = map[string]interface{}
+	testCases := []struct {
+		original, modified, want interface{}
+	}{
+		{M{"a": "b"}, M{"a": "b"}, M{}},
+		{M{"a": "b"}, M{"a": "c"}, M{"a": "c"}},
+		{M{"a": "b"}, M{}, M{"a": nil}},
+		{M{"a": M{"b": "c", "d": "e"}}, M{"a": M{"b": "c", "d": "f"}, "g": 1}, M{"a": M{"d": "f"}, "g": 1}},
+		{M{"a": []int{1, 2}}, M{"a": []int{1, 3}}, M{"a": []int{1, 3}}},
+		{M{"a": "b"}, "c", "c"},
+	}
+	for _, test := range testCases {
+		original, _ := Marshal(test.original)
+		modified, _ := Marshal(test.modified)
+		want, _ := Marshal(test.want)
+		patch, err := CreateMergePatch(original, modified)
+		if err != nil {
+			t.Fatalf("binpack:CreateMergePatch(%v, %v) error %v", test.original, test.modified, err)
+		}
+		if !Equal(patch, want) {
+			t.Fatalf("binpack:CreateMergePatch(%v, %v) got %x; wanted %x", test.original, test.modified, patch, want)
+		}
+		got, err := MergePatch(original, patch)
+		if err != nil || !Equal(got, modified) {
+			t.Fatalf("binpack:MergePatch of created patch got %x, %v; wanted %x", got, err, modified)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+// Package msgpack transcodes between MessagePack and binpack.
+//
+// Values are converted type for type: nil to Nil, booleans to True and
+// False, str to String, bin to Blob, float 32 to Float, float 64 to Double,
+// arrays to Lists and maps to Dicts, both terminated by a Closure.
+// Integers keep their width where binpack can express it: int 8 and
+// uint 8 become Byte Integers, int 16 and uint 16 Short Integers, int 32
+// and uint 32 Int Integers, and every other integer a Long Integer. In the
+// other direction Long Integers are written as fixints when they fit, and
+// as int 64 or uint 64 otherwise, so that binpack data survives a round
+// trip through MessagePack, except for the sign of a negative zero.
+//
+// MessagePack extension types have no binpack counterpart and are
+// reported as errors.
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/theodesp/binpack"
+)
+
+// ToBinpack reads a stream of MessagePack values from r and writes their
+// binpack encodings to w. Arrays and maps are streamed, so memory use is
+// bounded by the largest str or bin value.
+func ToBinpack(r io.Reader, w io.Writer) error {
+	rd := reader{r: bufio.NewReader(r), w: bufio.NewWriter(w)}
+	for {
+		c, err := rd.r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			rd.off++
+			err = rd.value(c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return rd.w.Flush()
+}
+
+// FromBinpack reads a stream of binpack values from r and writes them to w
+// as MessagePack. MessagePack arrays and maps start with their length, so
+// each top-level binpack value is decoded in memory before it is written.
+func FromBinpack(r io.Reader, w io.Writer) error {
+	dec := binpack.NewDecoder(r)
+	bw := bufio.NewWriter(w)
+	for {
+		var v binpack.Value
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = write(bw, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// maxDepth is the deepest nesting of arrays and maps that ToBinpack
+// converts, the same as binpack.Unmarshal decodes.
+const maxDepth = 10000
+
+// reader holds the state of ToBinpack.
+type reader struct {
+	r     *bufio.Reader
+	w     *bufio.Writer
+	off   int64 // number of bytes read
+	depth int   // number of arrays and maps being converted
+}
+
+func (rd *reader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("msgpack: "+format+" at offset %d", append(args, rd.off-1)...)
+}
+
+// readN reads the next n bytes. A corrupt length fails with
+// io.ErrUnexpectedEOF at the end of the input instead of allocating n bytes.
+func (rd *reader) readN(n uint64) ([]byte, error) {
+	var b bytes.Buffer
+	m, err := io.CopyN(&b, rd.r, int64(n))
+	rd.off += m
+	if err == io.EOF || uint64(m) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	return b.Bytes(), err
+}
+
+// uint reads a big-endian unsigned integer of size bytes.
+func (rd *reader) uint(size int) (uint64, error) {
+	b, err := rd.readN(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// emit writes the binpack encoding of v.
+func (rd *reader) emit(v binpack.Value) error {
+	b, err := v.MarshalBinpack()
+	if err == nil {
+		_, err = rd.w.Write(b)
+	}
+	return err
+}
+
+// value converts the value whose first byte c has just been read.
+func (rd *reader) value(c byte) error {
+	switch {
+	case c <= 0x7f:
+		return rd.emit(binpack.NewInt(int64(c), binpack.IntegerTypeLong))
+	case c >= 0xe0:
+		return rd.emit(binpack.NewInt(int64(int8(c)), binpack.IntegerTypeLong))
+	case c&0xf0 == 0x80:
+		return rd.container(binpack.Dict, uint64(c&0x0f))
+	case c&0xf0 == 0x90:
+		return rd.container(binpack.List, uint64(c&0x0f))
+	case c&0xe0 == 0xa0:
+		return rd.str(binpack.String, uint64(c&0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return rd.emit(binpack.NewNil())
+	case 0xc2, 0xc3:
+		return rd.emit(binpack.NewBool(c == 0xc3))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := rd.uint(1 << (c - 0xc4))
+		if err != nil {
+			return err
+		}
+		return rd.str(binpack.Blob, n)
+	case 0xd9, 0xda, 0xdb:
+		n, err := rd.uint(1 << (c - 0xd9))
+		if err != nil {
+			return err
+		}
+		return rd.str(binpack.String, n)
+	case 0xdc, 0xdd:
+		n, err := rd.uint(2 << (c - 0xdc))
+		if err != nil {
+			return err
+		}
+		return rd.container(binpack.List, n)
+	case 0xde, 0xdf:
+		n, err := rd.uint(2 << (c - 0xde))
+		if err != nil {
+			return err
+		}
+		return rd.container(binpack.Dict, n)
+	case 0xca, 0xcb:
+		u, err := rd.uint(4 << (c - 0xca))
+		if err != nil {
+			return err
+		}
+		if c == 0xca {
+			return rd.emit(binpack.NewFloat(math.Float32frombits(uint32(u))))
+		}
+		return rd.emit(binpack.NewDouble(math.Float64frombits(u)))
+	case 0xcc, 0xcd, 0xce, 0xcf, 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << ((c - 0xcc) % 4)
+		u, err := rd.uint(size)
+		if err != nil {
+			return err
+		}
+		typ := intTypes[size]
+		if c <= 0xcf {
+			return rd.emit(binpack.NewUint(u, typ))
+		}
+		shift := uint(64 - 8*size)
+		return rd.emit(binpack.NewInt(int64(u<<shift)>>shift, typ))
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xc7, 0xc8, 0xc9:
+		start := rd.off - 1
+		if c >= 0xc7 && c <= 0xc9 {
+			if _, err := rd.uint(1 << (c - 0xc7)); err != nil {
+				return err
+			}
+		}
+		t, err := rd.uint(1)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("msgpack: unsupported ext type %d at offset %d", int8(t), start)
+	}
+	return rd.errorf("invalid code 0x%02x", c)
+}
+
+// intTypes maps the size of a MessagePack integer to a binpack subtype.
+var intTypes = map[int]binpack.Code{
+	1: binpack.IntegerTypeByte,
+	2: binpack.IntegerTypeShort,
+	4: binpack.IntegerTypeInt,
+	8: binpack.IntegerTypeLong,
+}
+
+// str converts a str or bin payload of n bytes to a String or Blob.
+func (rd *reader) str(code binpack.Code, n uint64) error {
+	b, err := rd.readN(n)
+	if err != nil {
+		return err
+	}
+	if code == binpack.String {
+		return rd.emit(binpack.NewString(string(b)))
+	}
+	return rd.emit(binpack.NewBlob(b))
+}
+
+// container converts an array of n elements to a List, or a map of n
+// entries to a Dict.
+func (rd *reader) container(code binpack.Code, n uint64) error {
+	if rd.depth == maxDepth {
+		return rd.errorf("nesting deeper than %d", maxDepth)
+	}
+	rd.depth++
+	if code == binpack.Dict {
+		n *= 2
+	}
+	rd.w.WriteByte(byte(code))
+	for i := uint64(0); i < n; i++ {
+		c, err := rd.r.ReadByte()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		rd.off++
+		if err := rd.value(c); err != nil {
+			return err
+		}
+	}
+	rd.depth--
+	return rd.w.WriteByte(byte(binpack.Closure))
+}
+
+// errTooLong is returned for values that MessagePack cannot hold.
+var errTooLong = errors.New("msgpack: value longer than 2^32-1 cannot be written")
+
+// write writes v as MessagePack.
+func write(w *bufio.Writer, v binpack.Value) error {
+	switch v.Kind() {
+	case binpack.Nil:
+		w.WriteByte(0xc0)
+	case binpack.True:
+		w.WriteByte(0xc3)
+	case binpack.False:
+		w.WriteByte(0xc2)
+	case binpack.Integer:
+		return writeInt(w, v)
+	case binpack.Float:
+		w.WriteByte(0xca)
+		writeUint(w, uint64(math.Float32bits(float32(v.Float()))), 4)
+	case binpack.Double:
+		w.WriteByte(0xcb)
+		writeUint(w, math.Float64bits(v.Float()), 8)
+	case binpack.String:
+		if err := writeHeader(w, v.Len(), 0xa0, 32, 0xd9, 0xda, 0xdb); err != nil {
+			return err
+		}
+		w.WriteString(v.Str())
+	case binpack.Blob:
+		if err := writeHeader(w, v.Len(), 0, 0, 0xc4, 0xc5, 0xc6); err != nil {
+			return err
+		}
+		w.Write(v.Bytes())
+	case binpack.List:
+		if err := writeHeader(w, v.Len(), 0x90, 16, 0, 0xdc, 0xdd); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := write(w, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case binpack.Dict:
+		if err := writeHeader(w, v.Len(), 0x80, 16, 0, 0xde, 0xdf); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			e := v.Entry(i)
+			if err := write(w, e.Key); err != nil {
+				return err
+			}
+			if err := write(w, e.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeHeader writes the header of a str, bin, array or map of length n,
+// using the fix format fix|n for lengths below fixMax and otherwise the
+// smallest of the formats c8, c16 and c32 that holds n. A zero c8 means
+// there is no format with an 8 bit length.
+func writeHeader(w *bufio.Writer, n int, fix byte, fixMax int, c8, c16, c32 byte) error {
+	switch {
+	case n < fixMax:
+		w.WriteByte(fix | byte(n))
+	case n <= math.MaxUint8 && c8 != 0:
+		w.WriteByte(c8)
+		writeUint(w, uint64(n), 1)
+	case n <= math.MaxUint16:
+		w.WriteByte(c16)
+		writeUint(w, uint64(n), 2)
+	case uint64(n) <= math.MaxUint32:
+		w.WriteByte(c32)
+		writeUint(w, uint64(n), 4)
+	default:
+		return errTooLong
+	}
+	return nil
+}
+
+// intFormats maps binpack integer subtypes to the size of the MessagePack
+// integer they are written as, and the codes of its uint and int formats.
+var intFormats = map[binpack.Code]struct {
+	size      int
+	uint, int byte
+}{
+	binpack.IntegerTypeByte:  {1, 0xcc, 0xd0},
+	binpack.IntegerTypeShort: {2, 0xcd, 0xd1},
+	binpack.IntegerTypeInt:   {4, 0xce, 0xd2},
+	binpack.IntegerTypeLong:  {8, 0xcf, 0xd3},
+}
+
+// writeInt writes an Integer using the format of its subtype, or int 64 or
+// uint 64 if the value does not fit it. Long Integers use fixints when
+// they can.
+func writeInt(w *bufio.Writer, v binpack.Value) error {
+	long := v.IntType() == binpack.IntegerTypeLong
+	f := intFormats[v.IntType()]
+	if !v.IsNegative() {
+		u := v.Uint()
+		if long && u <= 0x7f {
+			return w.WriteByte(byte(u))
+		}
+		if f.size < 8 && u>>uint(8*f.size) != 0 {
+			f = intFormats[binpack.IntegerTypeLong]
+		}
+		w.WriteByte(f.uint)
+		return writeUint(w, u, f.size)
+	}
+	i, ok := negativeInt(v)
+	if !ok {
+		return fmt.Errorf("msgpack: Integer %s overflows int64", v)
+	}
+	if long && i >= -32 {
+		return w.WriteByte(byte(int8(i)))
+	}
+	if f.size < 8 && i < -1<<uint(8*f.size-1) {
+		f = intFormats[binpack.IntegerTypeLong]
+	}
+	w.WriteByte(f.int)
+	return writeUint(w, uint64(i), f.size)
+}
+
+// negativeInt returns the value of a negative Integer, reporting false if
+// it does not fit an int64.
+func negativeInt(v binpack.Value) (int64, bool) {
+	b := v.BigInt()
+	if !b.IsInt64() {
+		return 0, false
+	}
+	return b.Int64(), true
+}
+
+// writeUint writes the low size bytes of u in big-endian order.
+func writeUint(w *bufio.Writer, u uint64, size int) error {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], u)
+	_, err := w.Write(b[8-size:])
+	return err
+}
@@ -0,0 +1,120 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/theodesp/binpack"
+)
+
+func TestToBinpack(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{"c0c3c2", "nil\ntrue\nfalse"},
+		{"057fffe0", "5\n127\n-1\n-32"},
+		{"ccffd080cd0100d1ff00ceffffffffd2ffffffff", "255i8\n-128i8\n256i16\n-256i16\n4294967295i32\n-1i32"},
+		{"cfffffffffffffffffd38000000000000000", "18446744073709551615\n-9223372036854775808"},
+		{"ca3fc00000cb3ff8000000000000", "1.5f\n1.5"},
+		{"a3616263d90178da0000c4020102c400", `"abc"` + "\n" + `"x"` + "\n" + `""` + "\n" + "h'0102'\nh''"},
+		{"920102dc000190", "[1, 2]\n[[]]"},
+		{"82a161c001de0000", `{"a": nil, 1: {}}`},
+	}
+	for _, test := range testCases {
+		in, _ := hex.DecodeString(test.in)
+		var out bytes.Buffer
+		if err := ToBinpack(bytes.NewReader(in), &out); err != nil {
+			t.Fatalf("msgpack:ToBinpack(%s) error %v", test.in, err)
+		}
+		if got := binpack.Format(out.Bytes()); got != test.want {
+			t.Fatalf("msgpack:ToBinpack(%s) got %s; wanted %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestToBinpack_Errors(t *testing.T) {
+	testCases := []struct {
+		in  string
+		err string
+	}{
+		{"d40500", "msgpack: unsupported ext type 5 at offset 0"},
+		{"91c701ff00", "msgpack: unsupported ext type -1 at offset 1"},
+		{"c1", "msgpack: invalid code 0xc1 at offset 0"},
+		{"9201", io.ErrUnexpectedEOF.Error()},
+		{"dbffffffff61", io.ErrUnexpectedEOF.Error()},
+		{"cd01", io.ErrUnexpectedEOF.Error()},
+		{strings.Repeat("91", 10001), "msgpack: nesting deeper than 10000 at offset 10000"},
+	}
+	for _, test := range testCases {
+		in, _ := hex.DecodeString(test.in)
+		err := ToBinpack(bytes.NewReader(in), &bytes.Buffer{})
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("msgpack:ToBinpack(%.20s) got error %v; wanted %s", test.in, err, test.err)
+		}
+	}
+}
+
+func TestFromBinpack(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{"nil true false", "c0c3c2"},
+		{"5 127 128 -1 -32 -33", "057fcf0000000000000080ffe0d3ffffffffffffffdf"},
+		{"5i8 300i8 -5i16 7i32", "cc05cf000000000000012cd1fffbce00000007"},
+		{"1.5f 1.5", "ca3fc00000cb3ff8000000000000"},
+		{`"abc" h'0102'`, "a3616263c4020102"},
+		{`[1, [], {"a": nil}]`, "930190" + "81a161c0"},
+	}
+	for _, test := range testCases {
+		data, err := binpack.Parse(test.in)
+		if err != nil {
+			t.Fatalf("binpack:Parse(%s) error %v", test.in, err)
+		}
+		var out bytes.Buffer
+		if err := FromBinpack(bytes.NewReader(data), &out); err != nil {
+			t.Fatalf("msgpack:FromBinpack(%s) error %v", test.in, err)
+		}
+		if got := hex.EncodeToString(out.Bytes()); got != test.want {
+			t.Fatalf("msgpack:FromBinpack(%s) got %s; wanted %s", test.in, got, test.want)
+		}
+	}
+
+	data, _ := binpack.Parse("-18446744073709551615")
+	if err := FromBinpack(bytes.NewReader(data), &bytes.Buffer{}); err == nil {
+		t.Fatal("msgpack:FromBinpack of an Integer overflowing int64 expected error: got none")
+	}
+	if err := FromBinpack(bytes.NewReader([]byte{0x02, 0x41}), &bytes.Buffer{}); err == nil {
+		t.Fatal("msgpack:FromBinpack of truncated data expected error: got none")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	texts := []string{
+		`{"id": 1, "name": "ann", "tags": ["a", "b"], "ratio": 0.25, "f": 0.5f}`,
+		`[-9223372036854775808, 18446744073709551615, -128i8, 255i8, -32768i16, 65535i16, -2147483648i32, 4294967295i32]`,
+		`{h'00ff': [nil, true, false, {}]}`,
+		`"` + strings.Repeat("x", 70000) + `"`,
+		`[` + strings.Repeat("1, ", 20) + `1]`,
+	}
+	for _, text := range texts {
+		data, err := binpack.Parse(text)
+		if err != nil {
+			t.Fatalf("binpack:Parse error %v", err)
+		}
+		var mp, out bytes.Buffer
+		if err := FromBinpack(bytes.NewReader(data), &mp); err != nil {
+			t.Fatalf("msgpack:FromBinpack error %v", err)
+		}
+		if err := ToBinpack(&mp, &out); err != nil {
+			t.Fatalf("msgpack:ToBinpack error %v", err)
+		}
+		if !bytes.Equal(out.Bytes(), data) {
+			t.Fatalf("msgpack round trip of %.80s got %.80s", text, binpack.Format(out.Bytes()))
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package binpack
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Format returns a JSON-like text notation of the values encoded in data,
+// one value per line, that keeps the distinctions JSON loses:
+//
+//	nil, true, false
+//	5, -5            Integer of subtype Long
+//	5i8, 5i16, 5i32  Integer of subtype Byte, Short or Int
+//	3.14f            Float
+//	3.14, 1.0        Double, always written with a '.' or an exponent
+//	NaN, Infinity    non-finite Double, or Float with an 'f' suffix
+//	"text"           String, quoted like a Go string literal
+//	h'0102'          Blob, in hexadecimal
+//	[1, 2]           List
+//	{"a": 1, 2: 3}   Dict, in encoding order
+//
+// Parse turns the notation back into the encoding. If data is malformed
+// the notation ends with the error, enclosed in angle brackets.
+func Format(data []byte) string {
+	var b strings.Builder
+	d := decodeState{data: data}
+	for d.off < len(data) {
+		if d.off > 0 {
+			b.WriteByte('\n')
+		}
+		v, err := d.nextTree()
+		if err != nil {
+			b.WriteString("<" + err.Error() + ">")
+			break
+		}
+		v.format(&b)
+	}
+	return b.String()
+}
+
+// String returns the notation of v described on Format.
+func (v Value) String() string {
+	var b strings.Builder
+	v.format(&b)
+	return b.String()
+}
+
+// nextTree decodes the next value into a Value.
+func (d *decodeState) nextTree() (v Value, err error) {
+	defer catchError(&err)
+	return d.tree(), nil
+}
+
+func (v Value) format(b *strings.Builder) {
+	switch v.Kind() {
+	case Nil:
+		b.WriteString("nil")
+	case True:
+		b.WriteString("true")
+	case False:
+		b.WriteString("false")
+	case Integer:
+		if v.neg {
+			b.WriteByte('-')
+		}
+		b.WriteString(strconv.FormatUint(v.n, 10))
+		switch v.itype {
+		case IntegerTypeByte:
+			b.WriteString("i8")
+		case IntegerTypeShort:
+			b.WriteString("i16")
+		case IntegerTypeInt:
+			b.WriteString("i32")
+		}
+	case Float:
+		b.WriteString(formatFloat(v.Float(), 32))
+		b.WriteByte('f')
+	case Double:
+		s := formatFloat(v.Float(), 64)
+		b.WriteString(s)
+		if !strings.ContainsAny(s, ".eNI") {
+			b.WriteString(".0")
+		}
+	case String:
+		b.WriteString(strconv.Quote(v.s))
+	case Blob:
+		b.WriteString("h'" + hex.EncodeToString(v.b) + "'")
+	case List:
+		b.WriteByte('[')
+		for i, e := range v.list {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			e.format(b)
+		}
+		b.WriteByte(']')
+	case Dict:
+		b.WriteByte('{')
+		for i, e := range v.dict {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			e.Key.format(b)
+			b.WriteString(": ")
+			e.Value.format(b)
+		}
+		b.WriteByte('}')
+	}
+}
+
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+// Parse returns the encoding of the values written in the notation
+// described on Format, separated by white space. Errors are reported as a
+// *SyntaxError whose Offset is the position in text.
+func Parse(text string) (data []byte, err error) {
+	defer catchError(&err)
+	p := parser{text: text}
+	enc := new(Encoder)
+	for p.skipSpace(); p.off < len(text); p.skipSpace() {
+		enc.encodeTree(p.value())
+	}
+	return enc.buf.Bytes(), nil
+}
+
+// parser reads the notation described on Format.
+type parser struct {
+	text  string
+	off   int
+	depth int // number of Lists and Dicts being parsed
+}
+
+func (p *parser) errorf(format string, args ...interface{}) {
+	error_(syntaxErrorf(p.off, format, args...))
+}
+
+// enter records that the List or Dict at p.off is being parsed.
+func (p *parser) enter() {
+	p.depth++
+	if p.depth > maxDepth {
+		p.errorf("exceeded max depth of %d", maxDepth)
+	}
+	p.off++
+}
+
+func (p *parser) skipSpace() {
+	for p.off < len(p.text) && strings.IndexByte(" \t\r\n", p.text[p.off]) >= 0 {
+		p.off++
+	}
+}
+
+// expect consumes c, after any white space.
+func (p *parser) expect(c byte) {
+	p.skipSpace()
+	if p.off >= len(p.text) || p.text[p.off] != c {
+		p.errorf("expected %q", c)
+	}
+	p.off++
+}
+
+// next reports whether c follows, after any white space, and consumes it
+// if it does.
+func (p *parser) next(c byte) bool {
+	p.skipSpace()
+	if p.off < len(p.text) && p.text[p.off] == c {
+		p.off++
+		return true
+	}
+	return false
+}
+
+func (p *parser) value() Value {
+	p.skipSpace()
+	if p.off >= len(p.text) {
+		p.errorf("unexpected end of text")
+	}
+	switch c := p.text[p.off]; {
+	case c == '"':
+		return NewString(p.str())
+	case c == '[':
+		p.enter()
+		v := NewList()
+		for i := 0; !p.next(']'); i++ {
+			if i > 0 {
+				p.expect(',')
+			}
+			v.list = append(v.list, p.value())
+		}
+		p.depth--
+		return v
+	case c == '{':
+		p.enter()
+		v := NewDict()
+		for i := 0; !p.next('}'); i++ {
+			if i > 0 {
+				p.expect(',')
+			}
+			k := p.value()
+			p.expect(':')
+			v.dict = append(v.dict, Entry{Key: k, Value: p.value()})
+		}
+		p.depth--
+		return v
+	case strings.HasPrefix(p.text[p.off:], "h'"):
+		start := p.off
+		end := strings.IndexByte(p.text[p.off+2:], '\'')
+		if end < 0 {
+			p.errorf("unterminated Blob")
+		}
+		b, err := hex.DecodeString(p.text[p.off+2 : p.off+2+end])
+		if err != nil {
+			p.off = start
+			p.errorf("invalid Blob: %v", err)
+		}
+		p.off += end + 3
+		return NewBlob(b)
+	}
+	start := p.off
+	word := p.word()
+	switch word {
+	case "nil":
+		return NewNil()
+	case "true":
+		return NewBool(true)
+	case "false":
+		return NewBool(false)
+	case "":
+		p.errorf("invalid character %q", p.text[p.off])
+	}
+	v, ok := parseNumber(word)
+	if !ok {
+		p.off = start
+		p.errorf("invalid value %q", word)
+	}
+	return v
+}
+
+// word consumes the longest run of characters that can make up a keyword
+// or a number.
+func (p *parser) word() string {
+	start := p.off
+	for ; p.off < len(p.text); p.off++ {
+		c := p.text[p.off]
+		sign := (c == '-' || c == '+') &&
+			(p.off == start || p.text[p.off-1] == 'e' || p.text[p.off-1] == 'E')
+		if !sign && c != '.' && c != '_' &&
+			!('0' <= c && c <= '9') && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') {
+			break
+		}
+	}
+	return p.text[start:p.off]
+}
+
+// str consumes a quoted String.
+func (p *parser) str() string {
+	end := p.off + 1
+	for ; end < len(p.text) && p.text[end] != '"'; end++ {
+		if p.text[end] == '\\' {
+			end++
+		}
+	}
+	if end >= len(p.text) {
+		p.errorf("unterminated String")
+	}
+	s, err := strconv.Unquote(p.text[p.off : end+1])
+	if err != nil {
+		p.errorf("invalid String: %v", err)
+	}
+	p.off = end + 1
+	return s
+}
+
+var intSuffixes = []struct {
+	suffix string
+	typ    Code
+}{
+	{"i8", IntegerTypeByte},
+	{"i16", IntegerTypeShort},
+	{"i32", IntegerTypeInt},
+	{"", IntegerTypeLong},
+}
+
+// parseNumber parses an Integer, Float or Double.
+func parseNumber(s string) (Value, bool) {
+	digits := strings.TrimPrefix(s, "-")
+	for _, t := range intSuffixes {
+		if !strings.HasSuffix(digits, t.suffix) {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSuffix(digits, t.suffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		v := NewUint(n, t.typ)
+		v.neg = len(digits) < len(s)
+		return v, true
+	}
+	if strings.HasSuffix(s, "f") {
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "f"), 32)
+		return NewFloat(float32(f)), err == nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return NewDouble(f), err == nil
+}
@@ -0,0 +1,127 @@
+package binpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, "nil"},
+		{true, "true"},
+		{false, "false"},
+		{5, "5"},
+		{-5, "-5"},
+		{int8(5), "5i8"},
+		{int16(-300), "-300i16"},
+		{uint32(7), "7i32"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{float32(3.14), "3.14f"},
+		{float32(3), "3f"},
+		{3.14, "3.14"},
+		{1.0, "1.0"},
+		{1e21, "1e+21"},
+		{math.Inf(-1), "-Infinity"},
+		{float32(math.NaN()), "NaNf"},
+		{"a\"b\n", `"a\"b\n"`},
+		{[]byte{1, 2}, "h'0102'"},
+		{[]byte{}, "h''"},
+		{[]interface{}{1, "a", nil}, `[1, "a", nil]`},
+		{[]int{}, "[]"},
+		{map[int8][]string{1: {"x"}}, `{1i8: ["x"]}`},
+		{map[string]int{}, "{}"},
+	}
+	for _, test := range testCases {
+		data, err := Marshal(test.in)
+		if err != nil {
+			t.Fatalf("binpack:Marshal(%v) error %v", test.in, err)
+		}
+		if got := Format(data); got != test.want {
+			t.Fatalf("binpack:Format(%x) got %s; wanted %s", data, got, test.want)
+		}
+	}
+
+	data, _ := hex.DecodeString("410f0221")
+	if got, want := Format(data), "1\nnil\n<unexpected EOF>"; got != want {
+		t.Fatalf("binpack:Format(%x) got %q; wanted %q", data, got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	testCases := []string{
+		"nil",
+		"-0",
+		"-0i8",
+		"18446744073709551615i32",
+		"-18446744073709551615",
+		"1.5e-07f",
+		"0.1",
+		"-Infinity",
+		"NaN",
+		"Infinityf",
+		`"\xffé"`,
+		"h'00ff'",
+		`{"a": [1i8, 2i16, h''], 3.0: {}, nil: true}`,
+		"1\n2.0\n\"three\"",
+	}
+	for _, text := range testCases {
+		data, err := Parse(text)
+		if err != nil {
+			t.Fatalf("binpack:Parse(%s) error %v", text, err)
+		}
+		if got := Format(data); got != text {
+			t.Fatalf("binpack:Format(Parse(%s)) got %s", text, got)
+		}
+	}
+
+	data, err := Parse("  [ 1 ,\n\t{ \"a\" : h'AB' } ]  5i8 ")
+	want, _ := hex.DecodeString("024103216111ab01014d")
+	if err != nil || !bytes.Equal(data, want) {
+		t.Fatalf("binpack:Parse got %x, %v; wanted %x", data, err, want)
+	}
+
+	errCases := []struct {
+		in  string
+		off int64
+	}{
+		{"[1, 2", 5},
+		{"[1 2]", 3},
+		{"{1}", 2},
+		{"5i64", 0},
+		{`"abc`, 0},
+		{"h'0g'", 0},
+		{"h'01", 0},
+		{"[1, @]", 4},
+		{"true false nul", 11},
+	}
+	for _, test := range errCases {
+		_, err := Parse(test.in)
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("binpack:Parse(%s) got error %v; wanted a *SyntaxError", test.in, err)
+		}
+		if se.Offset != test.off {
+			t.Fatalf("binpack:Parse(%s) error %v at offset %d; wanted %d", test.in, err, se.Offset, test.off)
+		}
+	}
+	_, err = Parse(strings.Repeat("[{1: ", 1<<20))
+	if se, ok := err.(*SyntaxError); !ok || se.Offset != 5*maxDepth/2 {
+		t.Fatalf("binpack:Parse of deeply nested Lists and Dicts got %v; wanted a SyntaxError at %d", err, 5*maxDepth/2)
+	}
+}
+
+func TestValue_String(t *testing.T) {
+	v := NewDict(Entry{NewString("a"), NewList(NewFloat(1), NewDouble(2))}, Entry{NewBool(true), NewBlob(nil)})
+	if got, want := v.String(), `{"a": [1f, 2.0], true: h''}`; got != want {
+		t.Fatalf("Value:String got %s; wanted %s", got, want)
+	}
+	if got, want := (Path{"n", NewInt(5, IntegerTypeByte)}).String(), ".n{5i8}"; got != want {
+		t.Fatalf("Path:String got %s; wanted %s", got, want)
+	}
+}
@@ -0,0 +1,222 @@
+package binpack
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Get returns the encoding of the value found by following path from the
+// first value in data, without decoding anything else. A string path
+// element selects the entry of a Dict with that String key and an int
+// element selects an element of a List. The returned RawMessage refers to
+// data. Get returns ErrNotFound if a key or index does not exist.
+//
+//	id, err := binpack.GetInt(msg, "users", 3, "id")
+func Get(data []byte, path ...interface{}) (RawMessage, error) {
+	start, end, _, err := locate(data, path)
+	if err != nil {
+		return nil, err
+	}
+	return RawMessage(data[start:end:end]), nil
+}
+
+// GetString returns the String or Blob found at path as a string.
+func GetString(data []byte, path ...interface{}) (string, error) {
+	var s string
+	err := getAs(data, path, &s)
+	return s, err
+}
+
+// GetBytes returns a copy of the Blob or String found at path.
+func GetBytes(data []byte, path ...interface{}) ([]byte, error) {
+	var b []byte
+	err := getAs(data, path, &b)
+	return b, err
+}
+
+// GetInt returns the Integer found at path.
+func GetInt(data []byte, path ...interface{}) (int64, error) {
+	var i int64
+	err := getAs(data, path, &i)
+	return i, err
+}
+
+// GetFloat returns the Float, Double or Integer found at path.
+func GetFloat(data []byte, path ...interface{}) (float64, error) {
+	var f float64
+	err := getAs(data, path, &f)
+	return f, err
+}
+
+// GetBool returns the True or False found at path.
+func GetBool(data []byte, path ...interface{}) (bool, error) {
+	var b bool
+	err := getAs(data, path, &b)
+	return b, err
+}
+
+func getAs(data []byte, path []interface{}, v interface{}) error {
+	raw, err := Get(data, path...)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(raw, v)
+}
+
+// Set returns a copy of data in which the value found by following path is
+// replaced by the encoding of value, which may be a RawMessage. If the last
+// path element is a key missing from its Dict, the entry is added at the end
+// of the Dict. If it is the length of its List, the value is appended to the
+// List. The enclosing Lists and Dicts stay valid, as only the bytes of the
+// value itself are replaced.
+func Set(data []byte, value interface{}, path ...interface{}) ([]byte, error) {
+	raw, err := Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	start, end, _, err := locate(data, path)
+	if err == ErrNotFound {
+		return insert(data, raw, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return splice(data, start, end, raw), nil
+}
+
+// insert adds raw to the Dict or List holding the last element of path.
+func insert(data, raw []byte, path []interface{}) ([]byte, error) {
+	last := path[len(path)-1]
+	pstart, pend, _, err := locate(data, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	switch last := last.(type) {
+	case string:
+		key, _ := Marshal(last)
+		raw = append(key, raw...)
+	case int:
+		if n := View(data[pstart:pend]).Len(); last != n {
+			return nil, ErrNotFound
+		}
+	}
+	// The Closure of the parent is its last byte.
+	return splice(data, pend-1, pend-1, raw), nil
+}
+
+// Delete returns a copy of data without the value found by following path.
+// Deleting a Dict key removes the whole entry and deleting a List element
+// shifts the following elements down by one.
+func Delete(data []byte, path ...interface{}) ([]byte, error) {
+	if len(path) == 0 {
+		return nil, errors.New("binpack: cannot delete the root value")
+	}
+	_, end, entry, err := locate(data, path)
+	if err != nil {
+		return nil, err
+	}
+	return splice(data, entry, end, nil), nil
+}
+
+// splice returns a new slice holding data with data[start:end] replaced by b.
+func splice(data []byte, start, end int, b []byte) []byte {
+	out := make([]byte, 0, len(data)-(end-start)+len(b))
+	out = append(out, data[:start]...)
+	out = append(out, b...)
+	return append(out, data[end:]...)
+}
+
+// locate returns the bounds of the value found by following path from the
+// first value in data, and the start of its entry: the offset of its key if
+// the value is in a Dict, or of the value itself otherwise.
+func locate(data []byte, path []interface{}) (start, end, entry int, err error) {
+	if end, err = skipValue(data, 0); err != nil {
+		return 0, 0, 0, err
+	}
+	for _, elem := range path {
+		h, _ := readHeader(data, start)
+		off := start + h.size
+		found := false
+		switch elem := elem.(type) {
+		case string:
+			if h.code != Dict {
+				return 0, 0, 0, fmt.Errorf("binpack: cannot select key %q of %v", elem, h.code)
+			}
+			for !found && Code(data[off]) != Closure {
+				kend, _ := skipValue(data, off)
+				vend, err := skipValue(data, kend)
+				if err != nil {
+					return 0, 0, 0, err
+				}
+				if keyEquals(data[off:kend], elem) {
+					start, end, entry, found = kend, vend, off, true
+				}
+				off = vend
+			}
+		case int:
+			if h.code != List {
+				return 0, 0, 0, fmt.Errorf("binpack: cannot select index %d of %v", elem, h.code)
+			}
+			for i := 0; !found && Code(data[off]) != Closure; i++ {
+				vend, _ := skipValue(data, off)
+				if i == elem {
+					start, end, entry, found = off, vend, off, true
+				}
+				off = vend
+			}
+		default:
+			return 0, 0, 0, fmt.Errorf("binpack: invalid path element of type %T", elem)
+		}
+		if !found {
+			return 0, 0, 0, ErrNotFound
+		}
+	}
+	return start, end, entry, nil
+}
+
+// A Path addresses a value inside a document. Its elements are, from the
+// outermost value inwards, Dict keys and List indexes: a string for a
+// String key, an int for a List index and a Value for any other key.
+type Path []interface{}
+
+// String returns the path in a jq-like notation, for example
+// .users[3].name. Keys that are not Strings are written in braces using the
+// notation of Format, as in .counts{5i8}. The root path is ".".
+func (p Path) String() string {
+	if len(p) == 0 {
+		return "."
+	}
+	var b strings.Builder
+	for _, elem := range p {
+		switch elem := elem.(type) {
+		case string:
+			if isIdent(elem) {
+				b.WriteString("." + elem)
+			} else {
+				b.WriteString("[" + strconv.Quote(elem) + "]")
+			}
+		case int:
+			b.WriteString("[" + strconv.Itoa(elem) + "]")
+		case Value:
+			b.WriteString("{" + elem.String() + "}")
+		}
+	}
+	return b.String()
+}
+
+// isIdent reports whether s can be written after a dot in a path.
+func isIdent(s string) bool {
+	for i, c := range s {
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && (i == 0 || !('0' <= c && c <= '9')) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// append returns a new Path with elem added at the end.
+func (p Path) append(elem interface{}) Path {
+	return append(p[:len(p):len(p)], elem)
+}
@@ -0,0 +1,181 @@
+package binpack
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	data, _ := Marshal(map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{"name": "ann", "id": 7, "score": 1.5, "admin": true},
+			map[string]interface{}{"name": "bob", "id": -8, "key": []byte{1, 2}},
+		},
+		"count": uint8(2),
+	})
+
+	raw, err := Get(data, "users", 1)
+	if err != nil {
+		t.Fatalf("binpack:Get error %v", err)
+	}
+	var user map[string]interface{}
+	if err := Unmarshal(raw, &user); err != nil || user["name"] != "bob" {
+		t.Fatalf("binpack:Get returned %x (%v)", raw, err)
+	}
+	if raw, _ := Get(data); !bytes.Equal(raw, data) {
+		t.Fatal("binpack:Get without a path should return the whole value")
+	}
+
+	if s, err := GetString(data, "users", 0, "name"); err != nil || s != "ann" {
+		t.Fatalf("binpack:GetString got %q, %v", s, err)
+	}
+	if i, err := GetInt(data, "users", 1, "id"); err != nil || i != -8 {
+		t.Fatalf("binpack:GetInt got %v, %v", i, err)
+	}
+	if i, err := GetInt(data, "count"); err != nil || i != 2 {
+		t.Fatalf("binpack:GetInt got %v, %v", i, err)
+	}
+	if f, err := GetFloat(data, "users", 0, "score"); err != nil || f != 1.5 {
+		t.Fatalf("binpack:GetFloat got %v, %v", f, err)
+	}
+	if b, err := GetBool(data, "users", 0, "admin"); err != nil || !b {
+		t.Fatalf("binpack:GetBool got %v, %v", b, err)
+	}
+	if b, err := GetBytes(data, "users", 1, "key"); err != nil || !bytes.Equal(b, []byte{1, 2}) {
+		t.Fatalf("binpack:GetBytes got %v, %v", b, err)
+	}
+}
+
+func TestGet_Errors(t *testing.T) {
+	data, _ := Marshal(map[string][]int{"a": {1, 2}})
+	testCases := []struct {
+		path     []interface{}
+		notFound bool
+	}{
+		{[]interface{}{"b"}, true},
+		{[]interface{}{"a", 2}, true},
+		{[]interface{}{"a", -1}, true},
+		{[]interface{}{0}, false},
+		{[]interface{}{"a", "b"}, false},
+		{[]interface{}{"a", 0, 0}, false},
+		{[]interface{}{1.5}, false},
+	}
+	for _, test := range testCases {
+		_, err := Get(data, test.path...)
+		if err == nil {
+			t.Fatalf("binpack:Get(%v) expected error: got none", test.path)
+		}
+		if (err == ErrNotFound) != test.notFound {
+			t.Fatalf("binpack:Get(%v) got error %v", test.path, err)
+		}
+	}
+	if _, err := GetString(data, "a", 0); err == nil {
+		t.Fatal("binpack:GetString of an Integer expected error: got none")
+	}
+	if _, err := Get([]byte{0x03, 0x41, 0x01}, "x"); err == nil {
+		t.Fatal("binpack:Get on a Dict with a dangling key expected error: got none")
+	}
+	if _, err := Get([]byte{0x02, 0x41}, 0); err == nil {
+		t.Fatal("binpack:Get on truncated data expected error: got none")
+	}
+}
+
+func TestRawMessage(t *testing.T) {
+	in := map[string]RawMessage{"a": {0x41}, "nil": nil}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("binpack:Marshal error %v", err)
+	}
+	var out map[string]RawMessage
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("binpack:Unmarshal error %v", err)
+	}
+	if !bytes.Equal(out["a"], []byte{0x41}) || !bytes.Equal(out["nil"], []byte{0x0f}) {
+		t.Fatalf("binpack:Unmarshal into RawMessage got %v", out)
+	}
+	if _, err := Marshal(RawMessage{0x02}); err == nil {
+		t.Fatal("binpack:Marshal of an invalid RawMessage expected error: got none")
+	}
+}
+
+func TestSet(t *testing.T) {
+	data, _ := Marshal(map[string]interface{}{
+		"version": 1,
+		"items":   []string{"a", "b"},
+	})
+	out, err := Set(data, 2, "version")
+	if err != nil {
+		t.Fatalf("binpack:Set error %v", err)
+	}
+	if v, _ := GetInt(out, "version"); v != 2 {
+		t.Fatalf("binpack:Set got version %v; wanted %v", v, 2)
+	}
+	out, err = Set(out, map[string]int{"x": 1}, "items", 1)
+	if err != nil {
+		t.Fatalf("binpack:Set error %v", err)
+	}
+	if v, _ := GetInt(out, "items", 1, "x"); v != 1 {
+		t.Fatalf("binpack:Set got %v; wanted %v", v, 1)
+	}
+	out, err = Set(out, "c", "items", 2)
+	if err != nil {
+		t.Fatalf("binpack:Set append error %v", err)
+	}
+	out, err = Set(out, RawMessage{0x04}, "items", 1, "y")
+	if err != nil {
+		t.Fatalf("binpack:Set new key error %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := Unmarshal(out, &got); err != nil {
+		t.Fatalf("binpack:Unmarshal of patched data error %v", err)
+	}
+	items := got["items"].([]interface{})
+	if len(items) != 3 || items[2] != "c" || items[1].(map[interface{}]interface{})["y"] != true {
+		t.Fatalf("binpack:Set produced %v", got)
+	}
+	if v, _ := GetInt(data, "version"); v != 1 {
+		t.Fatal("binpack:Set modified its input")
+	}
+
+	if _, err := Set(out, 1, "items", 5); err != ErrNotFound {
+		t.Fatalf("binpack:Set past the end of a List expected %v: got %v", ErrNotFound, err)
+	}
+	if _, err := Set(out, 1, "missing", "key"); err != ErrNotFound {
+		t.Fatalf("binpack:Set below a missing key expected %v: got %v", ErrNotFound, err)
+	}
+	if _, err := Set(out, 1, "version", "key"); err == nil {
+		t.Fatal("binpack:Set below an Integer expected error: got none")
+	}
+	if root, _ := Set(out, "root"); !bytes.Equal(root, []byte{0x24, 'r', 'o', 'o', 't'}) {
+		t.Fatalf("binpack:Set without a path got %x", root)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	data, _ := Marshal(map[string]interface{}{
+		"token": "secret",
+		"items": []int{1, 2, 3},
+	})
+	out, err := Delete(data, "token")
+	if err != nil {
+		t.Fatalf("binpack:Delete error %v", err)
+	}
+	out, err = Delete(out, "items", 1)
+	if err != nil {
+		t.Fatalf("binpack:Delete error %v", err)
+	}
+	var got map[string][]int
+	if err := Unmarshal(out, &got); err != nil {
+		t.Fatalf("binpack:Unmarshal of patched data error %v", err)
+	}
+	if len(got) != 1 || len(got["items"]) != 2 || got["items"][1] != 3 {
+		t.Fatalf("binpack:Delete produced %v", got)
+	}
+	if _, err := Delete(out, "token"); err != ErrNotFound {
+		t.Fatalf("binpack:Delete of a missing key expected %v: got %v", ErrNotFound, err)
+	}
+	if _, err := Delete(out); err == nil {
+		t.Fatal("binpack:Delete of the root expected error: got none")
+	}
+}
@@ -0,0 +1,372 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/theodesp/binpack"
+)
+
+// token kinds.
+const (
+	tokEOF = iota
+	tokPunct
+	tokIdent
+	tokString
+	tokNumber
+)
+
+type token struct {
+	kind int
+	text string // punctuation, identifier, or the literal as written
+	pos  int
+}
+
+// lex splits src into tokens.
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(src) {
+				return nil, syntaxError(i, "unterminated string")
+			}
+			toks = append(toks, token{tokString, src[i : j+1], i})
+			i = j + 1
+		case isDigit(c) || c == '-' && i+1 < len(src) && isDigit(src[i+1]):
+			j := i + 1
+			for j < len(src) && (isDigit(src[j]) || strings.IndexByte(".eE", src[j]) >= 0 ||
+				(src[j] == '-' || src[j] == '+') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+				j++
+			}
+			toks = append(toks, token{tokNumber, src[i:j], i})
+			i = j
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(src) && (isIdentStart(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, src[i:j], i})
+			i = j
+		default:
+			p := src[i : i+1]
+			switch two := src[i:min(i+2, len(src))]; two {
+			case "==", "!=", "<=", ">=":
+				p = two
+			default:
+				if strings.IndexByte(".[]{}()|,:<>", c) < 0 {
+					return nil, syntaxError(i, "unexpected character %q", p)
+				}
+			}
+			toks = append(toks, token{tokPunct, p, i})
+			i += len(p)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(src)}), nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func syntaxError(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("query: "+format+" at position %d", append(args, pos)...)
+}
+
+// parser builds the syntax tree of a query. The grammar, from the lowest
+// precedence up, is:
+//
+//	pipe    = comma { "|" comma }
+//	comma   = or { "," or }
+//	or      = and { "or" and }
+//	and     = compare { "and" compare }
+//	compare = postfix [ ("==" | "!=" | "<" | "<=" | ">" | ">=") postfix ]
+//	postfix = primary { "." name | "[" [ index ] "]" }
+//	primary = "." [ name | "[" [ index ] "]" ] | literal | "(" pipe ")"
+//	        | "[" pipe "]" | "{" [ field { "," field } ] "}"
+//	        | "select" "(" pipe ")" | "not" | "length" | "keys"
+//	field   = name [ ":" or ]
+//	name    = identifier | string
+//	index   = number | string
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the punctuation s if it comes next.
+func (p *parser) accept(s string) bool {
+	if t := p.peek(); t.kind == tokPunct && t.text == s {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return unexpected(p.peek())
+	}
+	return nil
+}
+
+// unexpected returns the error for finding t where it does not belong.
+func unexpected(t token) error {
+	if t.kind == tokEOF {
+		return syntaxError(t.pos, "unexpected end of query")
+	}
+	return syntaxError(t.pos, "unexpected %q", t.text)
+}
+
+// acceptWord consumes the identifier w if it comes next.
+func (p *parser) acceptWord(w string) bool {
+	if t := p.peek(); t.kind == tokIdent && t.text == w {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) pipe() (node, error) {
+	n, err := p.comma()
+	for err == nil && p.accept("|") {
+		var right node
+		if right, err = p.comma(); err == nil {
+			n = pipeNode{n, right}
+		}
+	}
+	return n, err
+}
+
+func (p *parser) comma() (node, error) {
+	n, err := p.or()
+	for err == nil && p.accept(",") {
+		var right node
+		if right, err = p.or(); err == nil {
+			n = commaNode{n, right}
+		}
+	}
+	return n, err
+}
+
+func (p *parser) or() (node, error) {
+	n, err := p.and()
+	for err == nil && p.acceptWord("or") {
+		var right node
+		if right, err = p.and(); err == nil {
+			n = logicNode{or: true, left: n, right: right}
+		}
+	}
+	return n, err
+}
+
+func (p *parser) and() (node, error) {
+	n, err := p.compare()
+	for err == nil && p.acceptWord("and") {
+		var right node
+		if right, err = p.compare(); err == nil {
+			n = logicNode{left: n, right: right}
+		}
+	}
+	return n, err
+}
+
+func (p *parser) compare() (node, error) {
+	n, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			right, err := p.postfix()
+			if err != nil {
+				return nil, err
+			}
+			return compareNode{op, n, right}, nil
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) postfix() (node, error) {
+	n, err := p.primary()
+	for err == nil {
+		switch {
+		case p.accept("."):
+			var k binpack.Value
+			if k, err = p.name(); err == nil {
+				n = indexNode{n, k}
+			}
+		case p.accept("["):
+			n, err = p.bracket(n)
+		default:
+			return n, nil
+		}
+	}
+	return nil, err
+}
+
+// bracket parses what follows a "[" that indexes or iterates n.
+func (p *parser) bracket(n node) (node, error) {
+	if p.accept("]") {
+		return iterateNode{n}, nil
+	}
+	t := p.next()
+	var k binpack.Value
+	switch t.kind {
+	case tokNumber, tokString:
+		v, err := literal(t)
+		if err != nil {
+			return nil, err
+		}
+		k = v
+	default:
+		return nil, unexpected(t)
+	}
+	return indexNode{n, k}, p.expect("]")
+}
+
+// name parses a Dict key written as an identifier or a string.
+func (p *parser) name() (binpack.Value, error) {
+	t := p.next()
+	switch t.kind {
+	case tokIdent:
+		return binpack.NewString(t.text), nil
+	case tokString:
+		return literal(t)
+	}
+	return binpack.Value{}, unexpected(t)
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokPunct:
+		switch t.text {
+		case ".":
+			switch next := p.peek(); {
+			case next.kind == tokIdent || next.kind == tokString:
+				k, err := p.name()
+				return indexNode{identity{}, k}, err
+			case p.accept("["):
+				return p.bracket(identity{})
+			}
+			return identity{}, nil
+		case "(":
+			n, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			if p.accept("]") {
+				return literalNode{binpack.NewList()}, nil
+			}
+			n, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			return collectNode{n}, p.expect("]")
+		case "{":
+			return p.object()
+		}
+	case tokNumber, tokString:
+		v, err := literal(t)
+		return literalNode{v}, err
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return literalNode{binpack.NewBool(t.text == "true")}, nil
+		case "nil", "null":
+			return literalNode{binpack.NewNil()}, nil
+		case "not", "length", "keys":
+			return builtinNode(t.text), nil
+		case "select":
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			n, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			return selectNode{n}, p.expect(")")
+		}
+		return nil, syntaxError(t.pos, "unknown function %s", t.text)
+	}
+	return nil, unexpected(t)
+}
+
+// object parses a projection after its "{".
+func (p *parser) object() (node, error) {
+	var n objectNode
+	for i := 0; !p.accept("}"); i++ {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		k, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		var v node = indexNode{identity{}, k}
+		if p.accept(":") {
+			if v, err = p.or(); err != nil {
+				return nil, err
+			}
+		}
+		n = append(n, field{k, v})
+	}
+	return n, nil
+}
+
+// literal returns the value of a number or string token. Integers become
+// Long Integers and other numbers Doubles.
+func literal(t token) (binpack.Value, error) {
+	if t.kind == tokString {
+		s, err := strconv.Unquote(t.text)
+		if err != nil {
+			return binpack.Value{}, syntaxError(t.pos, "invalid string %s", t.text)
+		}
+		return binpack.NewString(s), nil
+	}
+	if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+		return binpack.NewInt(i, binpack.IntegerTypeLong), nil
+	}
+	if u, err := strconv.ParseUint(t.text, 10, 64); err == nil {
+		return binpack.NewUint(u, binpack.IntegerTypeLong), nil
+	}
+	f, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return binpack.Value{}, syntaxError(t.pos, "invalid number %s", t.text)
+	}
+	return binpack.NewDouble(f), nil
+}
@@ -0,0 +1,402 @@
+// Package query filters binpack values with a small jq-like language.
+//
+// A query is applied to a value and produces any number of results:
+//
+//	.                  the value itself
+//	.name, ."a b"      the value of a String key of a Dict, nil if missing
+//	.[2], .[-1]        an element of a List, counting from the end if
+//	                   negative, nil if out of range
+//	.["k"], .[5]       the value of a String or Integer Dict key
+//	.[]                every element of a List or value of a Dict
+//	a | b              b applied to every result of a
+//	a, b               the results of a followed by those of b
+//	select(f)          the value, if f produces a result other than false
+//	                   or nil
+//	==, !=, <, <=, >, >=, and, or, not
+//	                   comparisons and logic; numbers compare by value
+//	                   whatever their type
+//	[f]                a List of the results of f
+//	{id, n: .a.b}      a Dict projection, holding the first result of
+//	                   each field, or nil if there is none
+//	length             the number of characters of a String, bytes of a
+//	                   Blob, elements of a List or entries of a Dict
+//	keys               a List of the keys of a Dict
+//	1, 2.5, "s", true, false, nil
+//	                   literals
+//
+// Paths compose, as in .users[3].name or .items[].id. A path step that
+// does not apply to a value, such as .name on a List, produces no result,
+// so queries can run over heterogeneous streams.
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/theodesp/binpack"
+)
+
+// A Query is a parsed query. It is safe for concurrent use.
+type Query struct {
+	src  string
+	root node
+}
+
+// Parse parses a query.
+func Parse(src string) (*Query, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := parser{toks: toks}
+	root, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, unexpected(p.peek())
+	}
+	return &Query{src: src, root: root}, nil
+}
+
+// MustParse is like Parse but panics if the query cannot be parsed.
+func MustParse(src string) *Query {
+	q, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the source of the query.
+func (q *Query) String() string {
+	return q.src
+}
+
+// Eval applies the query to v and returns the results.
+func (q *Query) Eval(v binpack.Value) ([]binpack.Value, error) {
+	var results []binpack.Value
+	err := q.root.eval(v, func(r binpack.Value) error {
+		results = append(results, r)
+		return nil
+	})
+	return results, err
+}
+
+// Run applies the query to every value of the stream of concatenated
+// binpack values read from r, and calls emit with each result. Values are
+// decoded one at a time. An error returned by emit stops Run and is
+// returned.
+func (q *Query) Run(r io.Reader, emit func(binpack.Value) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<30)
+	sc.Split(binpack.ScanValues)
+	for sc.Scan() {
+		var v binpack.Value
+		if err := v.UnmarshalBinpack(sc.Bytes()); err != nil {
+			return err
+		}
+		if err := q.root.eval(v, emit); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// A node of the syntax tree calls emit with each of its results for v.
+type node interface {
+	eval(v binpack.Value, emit func(binpack.Value) error) error
+}
+
+type identity struct{}
+
+func (identity) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	return emit(v)
+}
+
+type literalNode struct {
+	v binpack.Value
+}
+
+func (n literalNode) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	return emit(n.v)
+}
+
+type pipeNode struct {
+	left, right node
+}
+
+func (n pipeNode) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	return n.left.eval(v, func(r binpack.Value) error {
+		return n.right.eval(r, emit)
+	})
+}
+
+type commaNode struct {
+	left, right node
+}
+
+func (n commaNode) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	if err := n.left.eval(v, emit); err != nil {
+		return err
+	}
+	return n.right.eval(v, emit)
+}
+
+// indexNode looks up the key or index k in the results of of.
+type indexNode struct {
+	of node
+	k  binpack.Value
+}
+
+func (n indexNode) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	return n.of.eval(v, func(v binpack.Value) error {
+		switch v.Kind() {
+		case binpack.Nil:
+			return emit(v)
+		case binpack.Dict:
+			for i := 0; i < v.Len(); i++ {
+				if e := v.Entry(i); compare(e.Key, n.k) == 0 {
+					return emit(e.Value)
+				}
+			}
+			return emit(binpack.NewNil())
+		case binpack.List:
+			if n.k.Kind() != binpack.Integer {
+				return nil
+			}
+			i, ok := toInt(n.k)
+			if ok && i < 0 {
+				i += v.Len()
+			}
+			if !ok || i < 0 || i >= v.Len() {
+				return emit(binpack.NewNil())
+			}
+			return emit(v.Index(i))
+		}
+		return nil
+	})
+}
+
+// toInt returns the value of an Integer as an int.
+func toInt(v binpack.Value) (int, bool) {
+	b := v.BigInt()
+	if !b.IsInt64() || b.Int64() > math.MaxInt32 || b.Int64() < math.MinInt32 {
+		return 0, false
+	}
+	return int(b.Int64()), true
+}
+
+// iterateNode produces the elements of the Lists and the values of the
+// Dicts in the results of of.
+type iterateNode struct {
+	of node
+}
+
+func (n iterateNode) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	return n.of.eval(v, func(v binpack.Value) error {
+		switch v.Kind() {
+		case binpack.List:
+			for i := 0; i < v.Len(); i++ {
+				if err := emit(v.Index(i)); err != nil {
+					return err
+				}
+			}
+		case binpack.Dict:
+			for i := 0; i < v.Len(); i++ {
+				if err := emit(v.Entry(i).Value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+type selectNode struct {
+	cond node
+}
+
+func (n selectNode) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	return n.cond.eval(v, func(r binpack.Value) error {
+		if truthy(r) {
+			return emit(v)
+		}
+		return nil
+	})
+}
+
+// truthy reports whether v counts as true: anything but False and Nil.
+func truthy(v binpack.Value) bool {
+	return v.Kind() != binpack.False && v.Kind() != binpack.Nil
+}
+
+// logicNode is "and", or "or" when or is set.
+type logicNode struct {
+	or          bool
+	left, right node
+}
+
+func (n logicNode) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	return n.left.eval(v, func(l binpack.Value) error {
+		if truthy(l) == n.or {
+			return emit(binpack.NewBool(n.or))
+		}
+		return n.right.eval(v, func(r binpack.Value) error {
+			return emit(binpack.NewBool(truthy(r)))
+		})
+	})
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	return n.left.eval(v, func(l binpack.Value) error {
+		return n.right.eval(v, func(r binpack.Value) error {
+			c := compare(l, r)
+			var b bool
+			switch n.op {
+			case "==":
+				b = c == 0
+			case "!=":
+				b = c != 0
+			case "<":
+				b = c == -1
+			case "<=":
+				b = c == -1 || c == 0
+			case ">":
+				b = c == 1
+			case ">=":
+				b = c == 1 || c == 0
+			}
+			return emit(binpack.NewBool(b))
+		})
+	})
+}
+
+// unordered is returned by compare for values that are neither equal nor
+// ordered.
+const unordered = 2
+
+// compare returns -1, 0 or 1 if a is less than, equal to or greater than b,
+// or unordered. Numbers of any type compare by value and Strings and Blobs
+// by their bytes. Other values are only ever equal or unordered.
+func compare(a, b binpack.Value) int {
+	ka, kb := a.Kind(), b.Kind()
+	switch {
+	case ka == binpack.Integer && kb == binpack.Integer:
+		return a.BigInt().Cmp(b.BigInt())
+	case isNumber(ka) && isNumber(kb):
+		fa, fb := bigFloat(a), bigFloat(b)
+		if fa == nil || fb == nil {
+			return unordered
+		}
+		return fa.Cmp(fb)
+	case ka == binpack.String && kb == binpack.String:
+		return strings.Compare(a.Str(), b.Str())
+	case ka == binpack.Blob && kb == binpack.Blob:
+		return bytes.Compare(a.Bytes(), b.Bytes())
+	}
+	if a.Equal(b) {
+		return 0
+	}
+	return unordered
+}
+
+func isNumber(k binpack.Code) bool {
+	return k == binpack.Integer || k == binpack.Float || k == binpack.Double
+}
+
+// bigFloat returns the exact value of a number, or nil for NaN.
+func bigFloat(v binpack.Value) *big.Float {
+	if v.Kind() == binpack.Integer {
+		return new(big.Float).SetInt(v.BigInt())
+	}
+	f := v.Float()
+	if math.IsNaN(f) {
+		return nil
+	}
+	return big.NewFloat(f)
+}
+
+// collectNode is a List of the results of n.
+type collectNode struct {
+	n node
+}
+
+func (n collectNode) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	l := binpack.NewList()
+	err := n.n.eval(v, func(r binpack.Value) error {
+		l.Append(r)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return emit(l)
+}
+
+type field struct {
+	key   binpack.Value
+	value node
+}
+
+// objectNode is a Dict projection.
+type objectNode []field
+
+func (n objectNode) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	d := binpack.NewDict()
+	for _, f := range n {
+		r := binpack.NewNil()
+		err := f.value.eval(v, func(first binpack.Value) error {
+			r = first
+			return errFirst
+		})
+		if err != nil && err != errFirst {
+			return err
+		}
+		d.SetKey(f.key.Str(), r)
+	}
+	return emit(d)
+}
+
+// errFirst stops an evaluation after its first result.
+var errFirst = errors.New("query: first result")
+
+// builtinNode is a function without arguments.
+type builtinNode string
+
+func (n builtinNode) eval(v binpack.Value, emit func(binpack.Value) error) error {
+	switch n {
+	case "not":
+		return emit(binpack.NewBool(!truthy(v)))
+	case "length":
+		switch v.Kind() {
+		case binpack.Nil:
+			return emit(binpack.NewInt(0, binpack.IntegerTypeLong))
+		case binpack.String:
+			return emit(binpack.NewInt(int64(utf8.RuneCountInString(v.Str())), binpack.IntegerTypeLong))
+		case binpack.Blob, binpack.List, binpack.Dict:
+			return emit(binpack.NewInt(int64(v.Len()), binpack.IntegerTypeLong))
+		}
+	case "keys":
+		if v.Kind() == binpack.Dict {
+			l := binpack.NewList()
+			for i := 0; i < v.Len(); i++ {
+				l.Append(v.Entry(i).Key)
+			}
+			return emit(l)
+		}
+	}
+	return fmt.Errorf("query: %s of %v", string(n), v.Kind())
+}
@@ -0,0 +1,151 @@
+package query
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/theodesp/binpack"
+)
+
+const doc = `{"users": [{"id": 1, "name": "ann", "age": 31i8, "tags": ["a", "b"]},` +
+	` {"id": 2, "name": "bob", "age": 17.5, "tags": []},` +
+	` {"id": 3, "name": "cy", "age": nil}], "n": 3i32, 5: h'01', "a b": true}`
+
+func mustValue(t *testing.T, notation string) binpack.Value {
+	data, err := binpack.Parse(notation)
+	if err != nil {
+		t.Fatalf("binpack.Parse(%q): %v", notation, err)
+	}
+	var v binpack.Value
+	if err := v.UnmarshalBinpack(data); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func format(results []binpack.Value) string {
+	s := make([]string, len(results))
+	for i, r := range results {
+		s[i] = r.String()
+	}
+	return strings.Join(s, "\n")
+}
+
+func TestEval(t *testing.T) {
+	v := mustValue(t, doc)
+	testCases := []struct {
+		query string
+		want  string
+	}{
+		{".", v.String()},
+		{".n", "3i32"},
+		{".missing", "nil"},
+		{".missing.deeper", "nil"},
+		{`."a b"`, "true"},
+		{`.["n"]`, "3i32"},
+		{".[5]", "h'01'"},
+		{".users[0].name", `"ann"`},
+		{".users[-1].id", "3"},
+		{".users[9]", "nil"},
+		{".users[].name", `"ann"` + "\n" + `"bob"` + "\n" + `"cy"`},
+		{".users[].tags[]", `"a"` + "\n" + `"b"`},
+		{".n[]", ""},
+		{".users.name", ""},
+		{".users[0] | .id, .name", "1\n" + `"ann"`},
+		{".users[] | select(.age > 18) | .name", `"ann"`},
+		{".users[] | select(.age < 18) | .name", `"bob"`},
+		{".users[] | select(.age == nil) | .id", "3"},
+		{".users[] | select(.id >= 2 and .name != \"cy\") | .id", "2"},
+		{".users[] | select(.id == 1 or .id == 3) | .id", "1\n3"},
+		{".users[] | select(.tags | length == 0) | .id", "2\n3"},
+		{".n == 3, .n == 3.0, .n < 3.5, .n > -1", "true\ntrue\ntrue\ntrue"},
+		{`"b" > "a", nil < 1, [1] == [1]`, "true\nfalse\ntrue"},
+		{"[.users[].id]", "[1, 2, 3]"},
+		{"[]", "[]"},
+		{".users[0] | {id, who: .name, tag: .tags[], x: .none}", `{"id": 1, "who": "ann", "tag": "a", "x": nil}`},
+		{`.users[1] | {"a b": .tags[]}`, `{"a b": nil}`},
+		{".users[0].name | length", "3"},
+		{"keys", `["users", "n", 5, "a b"]`},
+		{".users[2].age | not", "true"},
+		{"1, -2, 2.5e1, \"s\\n\", true, false, null", "1\n-2\n25.0\n\"s\\n\"\ntrue\nfalse\nnil"},
+	}
+	for _, test := range testCases {
+		q, err := Parse(test.query)
+		if err != nil {
+			t.Fatalf("query:Parse(%q): %v", test.query, err)
+		}
+		results, err := q.Eval(v)
+		if err != nil {
+			t.Fatalf("query:Eval(%q): %v", test.query, err)
+		}
+		if got := format(results); got != test.want {
+			t.Fatalf("query:Eval(%q) got\n%s\nwanted\n%s", test.query, got, test.want)
+		}
+	}
+}
+
+func TestEval_Error(t *testing.T) {
+	q := MustParse(".n | length")
+	if _, err := q.Eval(mustValue(t, `{"n": 1}`)); err == nil || err.Error() != "query: length of Integer" {
+		t.Fatalf("query:Eval got error %v", err)
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	testCases := []struct {
+		query string
+		want  string
+	}{
+		{"", "query: unexpected end of query at position 0"},
+		{".a |", "query: unexpected end of query at position 4"},
+		{".a b", `query: unexpected "b" at position 3`},
+		{".[", "query: unexpected end of query at position 2"},
+		{`."a`, "query: unterminated string at position 1"},
+		{".a ; .b", `query: unexpected character ";" at position 3`},
+		{"foo(.)", "query: unknown function foo at position 0"},
+		{"select(.a", "query: unexpected end of query at position 9"},
+		{"{a: 1,}", `query: unexpected "}" at position 6`},
+	}
+	for _, test := range testCases {
+		_, err := Parse(test.query)
+		if err == nil || err.Error() != test.want {
+			t.Fatalf("query:Parse(%q) got error %v; wanted %s", test.query, err, test.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	var in bytes.Buffer
+	for _, s := range []string{`{"id": 1}`, `[1, 2]`, `{"id": "x"}`} {
+		data, _ := binpack.Parse(s)
+		in.Write(data)
+	}
+	var got []binpack.Value
+	err := MustParse(".id").Run(&in, func(v binpack.Value) error {
+		got = append(got, v)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := format(got); s != "1\n\"x\"" {
+		t.Fatalf("query:Run got %s", s)
+	}
+
+	stop := errors.New("stop")
+	data, _ := binpack.Parse("1 2 3")
+	n := 0
+	err = MustParse(".").Run(bytes.NewReader(data), func(v binpack.Value) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Fatalf("query:Run got %v after %d results; wanted stop after 1", err, n)
+	}
+
+	if err := MustParse(".").Run(bytes.NewReader([]byte{0x02, 0x41}), func(binpack.Value) error { return nil }); err == nil {
+		t.Fatal("query:Run of a truncated stream succeeded")
+	}
+}
@@ -0,0 +1,195 @@
+package binpack
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// A SyntaxError is a description of malformed binpack data.
+type SyntaxError struct {
+	msg    string // description of error
+	Offset int64  // error occurred after reading Offset bytes
+}
+
+func (e *SyntaxError) Error() string { return "binpack: " + e.msg }
+
+func syntaxErrorf(off int, format string, args ...interface{}) *SyntaxError {
+	return &SyntaxError{msg: fmt.Sprintf(format, args...), Offset: int64(off)}
+}
+
+// header describes the leading bytes of an encoded value: the optional
+// length continuation bytes followed by the byte carrying the type code.
+type header struct {
+	code Code   // List, Dict, Closure, True, False, Double, Float, Nil, Blob, String or Integer
+	tag  Code   // last byte of the header, holding the integer subtype and sign
+	n    uint64 // payload length of a String or Blob, magnitude of an Integer
+	size int    // number of bytes in the header
+}
+
+// neg reports whether the header is the one of a negative Integer.
+func (h header) neg() bool {
+	return h.code == Integer && h.tag&MaskIntegerSign != 0
+}
+
+// intType returns the integer subtype, one of the IntegerType codes.
+func (h header) intType() Code {
+	return h.tag & MaskIntegerType
+}
+
+// payload returns the number of bytes following the header.
+func (h header) payload() uint64 {
+	switch h.code {
+	case Double:
+		return 8
+	case Float:
+		return 4
+	case String, Blob:
+		return h.n
+	}
+	return 0
+}
+
+// readHeader parses the header of the value starting at data[off].
+// It returns io.ErrUnexpectedEOF if data ends inside the header and a
+// *SyntaxError if the header is malformed.
+func readHeader(data []byte, off int) (header, error) {
+	var h header
+	var shift uint
+	for i := off; i < len(data); i++ {
+		c := Code(data[i])
+		if c&NumSignBit != 0 {
+			if !addBits(&h.n, uint64(c&NumMask), shift) {
+				return h, syntaxErrorf(off, "number overflows 64 bits")
+			}
+			shift += 7
+			continue
+		}
+
+		var bits Code
+		switch {
+		case c&Integer != 0: // 01sx xvvv
+			h.code, bits = Integer, c&maskLastIntegerBits
+		case c&String != 0: // 0010 xxxx
+			if c&Blob != 0 {
+				return h, syntaxErrorf(i, "invalid code 0x%02x", byte(c))
+			}
+			h.code, bits = String, c&MaskLastUintLen
+		case c&Blob != 0: // 0001 xxxx
+			h.code, bits = Blob, c&MaskLastUintLen
+		default:
+			if i > off {
+				return h, syntaxErrorf(i, "%v cannot follow length continuation bytes", c)
+			}
+			switch c {
+			case Closure, List, Dict, True, False, Double, Float, Nil:
+				h.code = c
+			default:
+				return h, syntaxErrorf(i, "invalid code 0x%02x", byte(c))
+			}
+		}
+		if !addBits(&h.n, uint64(bits), shift) {
+			return h, syntaxErrorf(off, "number overflows 64 bits")
+		}
+		h.tag = c
+		h.size = i + 1 - off
+		return h, nil
+	}
+	return h, io.ErrUnexpectedEOF
+}
+
+// addBits ors v << shift into n, reporting false if bits would be lost.
+func addBits(n *uint64, v uint64, shift uint) bool {
+	if v == 0 {
+		return true
+	}
+	if shift >= 64 || v>>(64-shift) != 0 {
+		return false
+	}
+	*n |= v << shift
+	return true
+}
+
+// int64 returns the value of an Integer header, reporting false if it does
+// not fit an int64.
+func (h header) int64() (int64, bool) {
+	if h.neg() {
+		if h.n > 1<<63 {
+			return 0, false
+		}
+		return -int64(h.n), true
+	}
+	if h.n > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(h.n), true
+}
+
+// describe returns a short description of the value, used in error messages.
+func (h header) describe() string {
+	if h.code != Integer {
+		return h.code.String()
+	}
+	if h.neg() {
+		return fmt.Sprintf("Integer -%d", h.n)
+	}
+	return fmt.Sprintf("Integer %d", h.n)
+}
+
+// Next returns the length of the first encoded value in data. For a List or
+// Dict this includes every nested value up to the matching Closure. Strings
+// and Blobs are skipped using their length prefix, so nothing is decoded or
+// allocated. Next returns io.ErrUnexpectedEOF if data ends inside the value.
+func Next(data []byte) (n int, err error) {
+	return skipValue(data, 0)
+}
+
+// skipValue returns the offset just past the value starting at data[off].
+func skipValue(data []byte, off int) (int, error) {
+	depth := 0
+	for {
+		h, err := readHeader(data, off)
+		if err != nil {
+			return 0, err
+		}
+		switch h.code {
+		case List, Dict:
+			depth++
+		case Closure:
+			if depth == 0 {
+				return 0, syntaxErrorf(off, "unexpected Closure")
+			}
+			depth--
+		}
+		off += h.size
+		n := h.payload()
+		if n > uint64(len(data)-off) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		off += int(n)
+		if depth == 0 {
+			return off, nil
+		}
+	}
+}
+
+// ScanValues is a split function for a bufio.Scanner that returns each
+// top-level binpack value of a stream of concatenated values as a token.
+// A value that is only partially buffered is completed by further reads.
+// If the input ends inside a value the Scanner stops with
+// io.ErrUnexpectedEOF, and malformed input stops it with a *SyntaxError.
+// Values larger than the Scanner's buffer need Scanner.Buffer to be raised.
+func ScanValues(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	n, err := Next(data)
+	if err == io.ErrUnexpectedEOF && !atEOF {
+		// Request more data.
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, data[0:n], nil
+}
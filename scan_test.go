@@ -0,0 +1,128 @@
+package binpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNext(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want int
+	}{
+		{"0f", 1},
+		{"41", 1},
+		{"ffffffffffffffffff4041", 10},
+		{"0700000000", 5},
+		{"0600000000000000000f", 9},
+		{"2568656c6c6f41", 6},
+		{"901000000000000000000000000000000000", 18},
+		{"0201", 2},
+		{"0221612162216301", 8},
+		{"0202404001024040010240400101", 14},
+		{"034126737472696e670141", 10},
+		{"0302410113616263010f", 9},
+	}
+	for _, test := range testCases {
+		data, _ := hex.DecodeString(test.in)
+		n, err := Next(data)
+		if err != nil {
+			t.Fatalf("binpack:Next(%s) error %v", test.in, err)
+		}
+		if n != test.want {
+			t.Fatalf("binpack:Next(%s) got %v; wanted %v", test.in, n, test.want)
+		}
+	}
+}
+
+func TestNext_Errors(t *testing.T) {
+	testCases := []struct {
+		in  string
+		eof bool
+	}{
+		{"", true},
+		{"02", true},
+		{"0202410141", true},
+		{"25616263", true},
+		{"9010", true},
+		{"06000000", true},
+		{"01", false},
+		{"00", false},
+		{"0e", false},
+		{"3f", false},
+		{"8102", false},
+		{"ffffffffffffffffffff40", false},
+	}
+	for _, test := range testCases {
+		data, _ := hex.DecodeString(test.in)
+		_, err := Next(data)
+		if test.eof && err != io.ErrUnexpectedEOF {
+			t.Fatalf("binpack:Next(%s) expected %v: got %v", test.in, io.ErrUnexpectedEOF, err)
+		}
+		if _, ok := err.(*SyntaxError); !test.eof && !ok {
+			t.Fatalf("binpack:Next(%s) expected *SyntaxError: got %v", test.in, err)
+		}
+	}
+}
+
+func TestNext_Allocs(t *testing.T) {
+	data, _ := Marshal(map[string][]interface{}{"a": {1, "two", []byte{3}, 4.0}})
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := Next(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("binpack:Next allocated %v times; wanted none", allocs)
+	}
+}
+
+func TestScanValues(t *testing.T) {
+	in := []interface{}{"a", []int{1, 2}, bytes.Repeat([]byte{7}, 100), map[string]int{"k": 1}, nil}
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	for _, v := range in {
+		_ = enc.Encode(v)
+	}
+	want := w.Bytes()
+
+	s := bufio.NewScanner(iotest.OneByteReader(bytes.NewReader(want)))
+	s.Buffer(make([]byte, 4), 1024)
+	s.Split(ScanValues)
+	var tokens [][]byte
+	for s.Scan() {
+		tokens = append(tokens, append([]byte{}, s.Bytes()...))
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("binpack:ScanValues error %v", err)
+	}
+	if len(tokens) != len(in) {
+		t.Fatalf("binpack:ScanValues got %v tokens; wanted %v", len(tokens), len(in))
+	}
+	if got := bytes.Join(tokens, nil); !bytes.Equal(got, want) {
+		t.Fatalf("binpack:ScanValues tokens do not add up to the input")
+	}
+	for i, tok := range tokens {
+		if n, err := Next(tok); err != nil || n != len(tok) {
+			t.Fatalf("binpack:ScanValues token %v is not a single value: % x", i, tok)
+		}
+	}
+}
+
+func TestScanValues_Truncated(t *testing.T) {
+	s := bufio.NewScanner(bytes.NewReader([]byte{0x41, 0x02, 0x41}))
+	s.Split(ScanValues)
+	if !s.Scan() || !bytes.Equal(s.Bytes(), []byte{0x41}) {
+		t.Fatalf("binpack:ScanValues expected the first value: got % x", s.Bytes())
+	}
+	if s.Scan() {
+		t.Fatalf("binpack:ScanValues expected no more values: got % x", s.Bytes())
+	}
+	if s.Err() != io.ErrUnexpectedEOF {
+		t.Fatalf("binpack:ScanValues expected %v: got %v", io.ErrUnexpectedEOF, s.Err())
+	}
+}
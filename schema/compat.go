@@ -0,0 +1,210 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/theodesp/binpack"
+)
+
+// A BreakingChange is a difference between two versions of a Schema that
+// can break the exchange of data between their readers and writers.
+type BreakingChange struct {
+	// Path locates the change in a document, in the notation of
+	// binpack.Path with [] for the elements of a List, as in .items[].id.
+	Path   string
+	Reason string
+}
+
+func (c BreakingChange) String() string {
+	return c.Path + ": " + c.Reason
+}
+
+// CheckCompatible returns the breaking changes from the Schema oldSchema to
+// newSchema, or nil if there are none:
+//
+//   - a required key removed, or made optional, which readers of the old
+//     version expect
+//   - a new required key, or an optional key made required, which writers
+//     of the old version leave out
+//   - a type no longer accepted, including an Integer changed to another
+//     subtype, such as long to short or short to long, since Validate
+//     requires the exact subtype
+//   - an Integer range that no longer contains the old one, such as that
+//     of uint8 changed to int8
+//   - a Dict closed to keys it does not list, which writers of the old
+//     version may send
+//
+// Changes to lengths and patterns are not reported.
+func CheckCompatible(oldSchema, newSchema *Schema) []BreakingChange {
+	var changes []BreakingChange
+	checkCompatible(oldSchema, newSchema, "", &changes)
+	return changes
+}
+
+func checkCompatible(o, n *Schema, path string, changes *[]BreakingChange) {
+	if o == nil {
+		o = &Schema{}
+	}
+	if n == nil {
+		n = &Schema{}
+	}
+	add := func(path, format string, args ...interface{}) {
+		if path == "" {
+			path = "."
+		}
+		*changes = append(*changes, BreakingChange{path, fmt.Sprintf(format, args...)})
+	}
+
+	oldTypes := o.Types
+	if len(oldTypes) == 0 {
+		oldTypes = []string{"any"}
+	}
+	typeChanges := len(*changes)
+	for _, t := range oldTypes {
+		if len(n.Types) == 0 || covered(t, n.Types) {
+			continue
+		}
+		other := closestInt(t, n.Types)
+		switch {
+		case other == "":
+			add(path, "type %s no longer accepted", t)
+		case intRanks[other] < intRanks[t]:
+			add(path, "integer narrowed from %s to %s", t, other)
+		default:
+			add(path, "integer widened from %s to %s", t, other)
+		}
+	}
+	// A range is only compared between Integers of compatible types.
+	if len(*changes) == typeChanges && acceptsInt(o) && acceptsInt(n) {
+		switch {
+		case n.Min == nil:
+		case o.Min == nil:
+			add(path, "minimum %v added", n.Min)
+		case n.Min.Cmp(o.Min) > 0:
+			add(path, "minimum raised from %v to %v", o.Min, n.Min)
+		}
+		switch {
+		case n.Max == nil:
+		case o.Max == nil:
+			add(path, "maximum %v added", n.Max)
+		case n.Max.Cmp(o.Max) < 0:
+			add(path, "maximum lowered from %v to %v", o.Max, n.Max)
+		}
+	}
+
+	if accepts(o, "list") && accepts(n, "list") {
+		checkCompatible(o.Elements, n.Elements, path+"[]", changes)
+	}
+	if !accepts(o, "dict") || !accepts(n, "dict") {
+		return
+	}
+	for _, k := range sortedKeys(o.Keys) {
+		if ns, ok := n.Keys[k]; ok {
+			checkCompatible(o.Keys[k], ns, keyPath(path, k), changes)
+		} else if _, ok := n.Optional[k]; ok {
+			add(keyPath(path, k), "required key made optional")
+		} else {
+			add(keyPath(path, k), "required key removed")
+		}
+	}
+	for _, k := range sortedKeys(o.Optional) {
+		if _, ok := n.Keys[k]; ok {
+			add(keyPath(path, k), "optional key made required")
+		} else if ns, ok := n.Optional[k]; ok {
+			checkCompatible(o.Optional[k], ns, keyPath(path, k), changes)
+		} else if n.Closed {
+			add(keyPath(path, k), "optional key removed from a closed Dict")
+		}
+	}
+	for _, k := range sortedKeys(n.Keys) {
+		_, required := o.Keys[k]
+		_, optional := o.Optional[k]
+		if !required && !optional {
+			add(keyPath(path, k), "new required key")
+		}
+	}
+	if n.Closed && !o.Closed {
+		add(path, "Dict closed to unlisted keys")
+	}
+}
+
+// intRanks orders the Integer types by the values they accept.
+var intRanks = map[string]int{"byte": 1, "short": 2, "int": 3, "long": 4, "integer": 5}
+
+// covered reports whether one of the types accepts every value of type t.
+// An Integer subtype only accepts itself, while integer and number accept
+// every subtype.
+func covered(t string, types []string) bool {
+	for _, nt := range types {
+		switch {
+		case nt == t, nt == "any":
+			return true
+		case nt == "integer" && intRanks[t] > 0:
+			return true
+		case nt == "number" && (intRanks[t] > 0 || t == "float" || t == "double"):
+			return true
+		}
+	}
+	return false
+}
+
+// closestInt returns the Integer type of types closest to the Integer
+// type t, preferring the widest narrower one, or "".
+func closestInt(t string, types []string) string {
+	closest := ""
+	for _, nt := range types {
+		r := intRanks[nt]
+		if r == 0 || intRanks[t] == 0 {
+			continue
+		}
+		c := intRanks[closest]
+		switch {
+		case closest == "",
+			r < intRanks[t] && (c > intRanks[t] || r > c),
+			r > intRanks[t] && c > intRanks[t] && r < c:
+			closest = nt
+		}
+	}
+	return closest
+}
+
+// accepts reports whether s accepts some values of type t.
+func accepts(s *Schema, t string) bool {
+	if s == nil || len(s.Types) == 0 {
+		return true
+	}
+	for _, st := range s.Types {
+		if st == t || st == "any" {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsInt reports whether s accepts some Integers.
+func acceptsInt(s *Schema) bool {
+	if len(s.Types) == 0 {
+		return true
+	}
+	for _, t := range s.Types {
+		if intRanks[t] > 0 || t == "number" || t == "any" {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]*Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// keyPath returns the path of the key k of the Dict at path.
+func keyPath(path, k string) string {
+	return path + binpack.Path{k}.String()
+}
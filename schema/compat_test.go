@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testV1 struct {
+	ID   int64   `binpack:"id"`
+	Name string  `binpack:"name"`
+	Age  int32   `binpack:"age,omitempty"`
+	Tags []int64 `binpack:"tags"`
+	Old  string  `binpack:"old"`
+	Opt  string  `binpack:"opt,omitempty"`
+}
+
+type testV2 struct {
+	ID   int16  `binpack:"id"`
+	Name []byte `binpack:"name"`
+	Age  int32  `binpack:"age"`
+	Tags []int8 `binpack:"tags"`
+	Old  string `binpack:"old,omitempty"`
+	New  bool   `binpack:"new"`
+}
+
+func mustFromType(t *testing.T, v interface{}) *Schema {
+	s, err := FromType(reflect.TypeOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func mustParseJSON(t *testing.T, desc string) *Schema {
+	s, err := ParseJSON([]byte(desc))
+	if err != nil {
+		t.Fatalf("schema:ParseJSON(%s): %v", desc, err)
+	}
+	return s
+}
+
+func TestCheckCompatible(t *testing.T) {
+	testCases := []struct {
+		old, new *Schema
+		want     []string
+	}{
+		{mustFromType(t, testV1{}), mustFromType(t, testV1{}), nil},
+		{mustFromType(t, testV1{}), mustFromType(t, testV2{}), []string{
+			".id: integer narrowed from long to short",
+			".name: type string no longer accepted",
+			".old: required key made optional",
+			".tags[]: integer narrowed from long to byte",
+			".age: optional key made required",
+			".new: new required key",
+		}},
+		{mustFromType(t, int16(0)), mustFromType(t, int64(0)), []string{".: integer widened from short to long"}},
+		{mustParseJSON(t, `{"type": "short"}`), mustParseJSON(t, `{"type": ["long", "int", "byte"]}`), []string{
+			".: integer narrowed from short to byte",
+		}},
+		{mustFromType(t, int64(0)), mustParseJSON(t, `{"type": "number"}`), nil},
+		{mustFromType(t, int16(0)), mustParseJSON(t, `{"type": "integer", "min": -40000}`), nil},
+		{mustFromType(t, uint8(0)), mustFromType(t, int8(0)), []string{".: maximum lowered from 255 to 127"}},
+		{mustFromType(t, uint64(0)), mustFromType(t, int64(0)), []string{
+			".: maximum lowered from 18446744073709551615 to 9223372036854775807",
+		}},
+		{mustFromType(t, int64(0)), mustFromType(t, uint64(0)), []string{".: minimum raised from -9223372036854775808 to 0"}},
+		{mustParseJSON(t, `{"type": "integer"}`), mustParseJSON(t, `{"type": "integer", "min": 0, "max": 9}`), []string{
+			".: minimum 0 added",
+			".: maximum 9 added",
+		}},
+		{mustParseJSON(t, `{"type": ["integer", "nil"]}`), mustParseJSON(t, `{"type": "long"}`), []string{
+			".: integer narrowed from integer to long",
+			".: type nil no longer accepted",
+		}},
+		{mustParseJSON(t, `{}`), mustParseJSON(t, `{"type": "string"}`), []string{".: type any no longer accepted"}},
+		{
+			mustParseJSON(t, `{"type": "dict", "optional": {"a b": {}, "c": {"type": "list", "elements": {"type": "long"}}}}`),
+			mustParseJSON(t, `{"type": "dict", "optional": {"c": {"type": "list", "elements": {"type": "bool"}}}, "closed": true}`),
+			[]string{
+				`["a b"]: optional key removed from a closed Dict`,
+				".c[]: type long no longer accepted",
+				".: Dict closed to unlisted keys",
+			},
+		},
+	}
+	for i, test := range testCases {
+		var got []string
+		for _, c := range CheckCompatible(test.old, test.new) {
+			got = append(got, c.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("schema:CheckCompatible case %d got\n%q\nwanted\n%q", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+
+	"github.com/theodesp/binpack"
+)
+
+// Parse returns the Schema described by the single binpack value in data,
+// as described in the package documentation.
+func Parse(data []byte) (*Schema, error) {
+	var v binpack.Value
+	if err := v.UnmarshalBinpack(data); err != nil {
+		return nil, err
+	}
+	return FromValue(v)
+}
+
+// ParseJSON returns the Schema described by the JSON document in data.
+func ParseJSON(data []byte) (*Schema, error) {
+	var b bytes.Buffer
+	if err := binpack.FromJSON(bytes.NewReader(data), &b); err != nil {
+		return nil, err
+	}
+	return Parse(b.Bytes())
+}
+
+// FromValue returns the Schema described by v.
+func FromValue(v binpack.Value) (*Schema, error) {
+	return describe(v, nil)
+}
+
+// A DescriptionError reports an invalid schema description.
+type DescriptionError struct {
+	Path   binpack.Path // path of the invalid entry in the description
+	Reason string
+}
+
+func (e *DescriptionError) Error() string {
+	return fmt.Sprintf("schema: %v: %s", e.Path, e.Reason)
+}
+
+func describe(v binpack.Value, path binpack.Path) (*Schema, error) {
+	fail := func(path binpack.Path, format string, args ...interface{}) error {
+		return &DescriptionError{Path: append(binpack.Path(nil), path...), Reason: fmt.Sprintf(format, args...)}
+	}
+	if v.Kind() != binpack.Dict {
+		return nil, fail(path, "schema must be a Dict, not %v", v.Kind())
+	}
+	s := new(Schema)
+	for i := 0; i < v.Len(); i++ {
+		e := v.Entry(i)
+		if e.Key.Kind() != binpack.String {
+			return nil, fail(path, "key %v is not a String", e.Key)
+		}
+		k, val := e.Key.Str(), e.Value
+		p := append(path, k)
+		var err error
+		switch k {
+		case "type":
+			types := []binpack.Value{val}
+			if val.Kind() == binpack.List {
+				types = types[:0]
+				for j := 0; j < val.Len(); j++ {
+					types = append(types, val.Index(j))
+				}
+			}
+			for _, t := range types {
+				if t.Kind() != binpack.String || typeNames[t.Str()] == nil {
+					return nil, fail(p, "unknown type %v", t)
+				}
+				s.Types = append(s.Types, t.Str())
+			}
+		case "keys", "optional":
+			if val.Kind() != binpack.Dict {
+				return nil, fail(p, "%v is not a Dict", val.Kind())
+			}
+			m := make(map[string]*Schema)
+			for j := 0; j < val.Len(); j++ {
+				ke := val.Entry(j)
+				if ke.Key.Kind() != binpack.String {
+					return nil, fail(p, "key %v is not a String", ke.Key)
+				}
+				if m[ke.Key.Str()], err = describe(ke.Value, append(p, ke.Key.Str())); err != nil {
+					return nil, err
+				}
+			}
+			if k == "keys" {
+				s.Keys = m
+			} else {
+				s.Optional = m
+			}
+		case "closed":
+			if val.Kind() != binpack.True && val.Kind() != binpack.False {
+				return nil, fail(p, "%v is not a bool", val.Kind())
+			}
+			s.Closed = val.Bool()
+		case "elements":
+			if s.Elements, err = describe(val, p); err != nil {
+				return nil, err
+			}
+		case "min", "max":
+			if val.Kind() != binpack.Integer {
+				return nil, fail(p, "%v is not an Integer", val.Kind())
+			}
+			if k == "min" {
+				s.Min = val.BigInt()
+			} else {
+				s.Max = val.BigInt()
+			}
+		case "minLength", "maxLength":
+			if val.Kind() != binpack.Integer || val.IsNegative() || val.BigInt().Cmp(big.NewInt(1<<31-1)) > 0 {
+				return nil, fail(p, "%v is not a length", val)
+			}
+			n := int(val.Uint())
+			if k == "minLength" {
+				s.MinLength = &n
+			} else {
+				s.MaxLength = &n
+			}
+		case "pattern":
+			if val.Kind() != binpack.String {
+				return nil, fail(p, "%v is not a String", val.Kind())
+			}
+			if s.Pattern, err = regexp.Compile(val.Str()); err != nil {
+				return nil, fail(p, "%v", err)
+			}
+		default:
+			return nil, fail(p, "unknown entry")
+		}
+	}
+	return s, nil
+}
+
+// Describe returns the description of s, which FromValue turns back into
+// an equivalent Schema.
+func (s *Schema) Describe() binpack.Value {
+	d := binpack.NewDict()
+	if s == nil {
+		return d
+	}
+	switch len(s.Types) {
+	case 0:
+	case 1:
+		d.SetKey("type", binpack.NewString(s.Types[0]))
+	default:
+		types := binpack.NewList()
+		for _, t := range s.Types {
+			types.Append(binpack.NewString(t))
+		}
+		d.SetKey("type", types)
+	}
+	for _, keys := range []struct {
+		name string
+		m    map[string]*Schema
+	}{{"keys", s.Keys}, {"optional", s.Optional}} {
+		if keys.m == nil {
+			continue
+		}
+		names := make([]string, 0, len(keys.m))
+		for k := range keys.m {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		m := binpack.NewDict()
+		for _, k := range names {
+			m.SetKey(k, keys.m[k].Describe())
+		}
+		d.SetKey(keys.name, m)
+	}
+	if s.Closed {
+		d.SetKey("closed", binpack.NewBool(true))
+	}
+	if s.Elements != nil {
+		d.SetKey("elements", s.Elements.Describe())
+	}
+	if s.Min != nil {
+		d.SetKey("min", binpack.NewBigInt(s.Min, binpack.IntegerTypeLong))
+	}
+	if s.Max != nil {
+		d.SetKey("max", binpack.NewBigInt(s.Max, binpack.IntegerTypeLong))
+	}
+	if s.MinLength != nil {
+		d.SetKey("minLength", binpack.NewInt(int64(*s.MinLength), binpack.IntegerTypeLong))
+	}
+	if s.MaxLength != nil {
+		d.SetKey("maxLength", binpack.NewInt(int64(*s.MaxLength), binpack.IntegerTypeLong))
+	}
+	if s.Pattern != nil {
+		d.SetKey("pattern", binpack.NewString(s.Pattern.String()))
+	}
+	return d
+}
+
+// MarshalBinpack returns the encoding of the description of s.
+func (s *Schema) MarshalBinpack() ([]byte, error) {
+	return s.Describe().MarshalBinpack()
+}
+
+// UnmarshalBinpack sets s to the Schema described by data.
+func (s *Schema) UnmarshalBinpack(data []byte) error {
+	p, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	*s = *p
+	return nil
+}
@@ -0,0 +1,268 @@
+// Package schema declares the expected shape of binpack documents and
+// checks documents against it.
+//
+// A Schema is usually loaded from a description, itself a binpack or JSON
+// Dict such as:
+//
+//	{
+//		"type": "dict",
+//		"keys": {
+//			"id": {"type": "long", "min": 1},
+//			"name": {"type": "string", "minLength": 1, "maxLength": 64},
+//			"tags": {"type": "list", "elements": {"type": "string", "pattern": "^[a-z]+$"}}
+//		},
+//		"optional": {
+//			"email": {"type": ["string", "nil"]}
+//		},
+//		"closed": true
+//	}
+//
+// The entries of a description are:
+//
+//	type       a type name, or a List of them, that the value must have
+//	keys       the required String keys of a Dict and the schemas of their values
+//	optional   the optional String keys of a Dict and the schemas of their values
+//	closed     whether a Dict may only hold the keys listed in keys and optional
+//	elements   the schema of every element of a List
+//	min, max   the inclusive range of an Integer
+//	minLength, maxLength
+//	           the inclusive range of the number of characters of a String,
+//	           bytes of a Blob, elements of a List or entries of a Dict
+//	pattern    a regular expression, as accepted by package regexp, that a
+//	           String must match
+//
+// The type names are nil, bool, byte, short, int and long for the Integer
+// subtypes, integer for any Integer, float, double, number for any Integer,
+// Float or Double, string, blob, list, dict and any. An Integer subtype
+// only accepts Integers encoded with that subtype, whatever their value.
+// Every entry is optional: an empty description accepts anything.
+//
+// FromType derives the Schema of the values marshalled from a Go type, and
+// CheckCompatible reports the breaking changes between two versions of a
+// Schema, such as those of two versions of a message struct.
+package schema
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/theodesp/binpack"
+)
+
+// A Schema describes the values accepted at one place of a document. The
+// zero Schema accepts any value.
+type Schema struct {
+	// Types lists the type names a value may have, as described in the
+	// package documentation. An empty list allows every type.
+	Types []string
+	// Keys and Optional hold the schemas of the values of the required and
+	// optional String keys of a Dict. A nil Schema accepts any value.
+	Keys     map[string]*Schema
+	Optional map[string]*Schema
+	// Closed rejects the keys of a Dict that are in neither Keys nor
+	// Optional.
+	Closed bool
+	// Elements is the schema of the elements of a List. Nil accepts any
+	// element.
+	Elements *Schema
+	// Min and Max bound the value of an Integer. Nil leaves it unbounded.
+	// Describe panics if their magnitude overflows a uint64.
+	Min, Max *big.Int
+	// MinLength and MaxLength bound the length of a String, Blob, List or
+	// Dict. Nil leaves it unbounded.
+	MinLength, MaxLength *int
+	// Pattern is a regular expression a String must match.
+	Pattern *regexp.Regexp
+}
+
+// A Violation is a way a value breaks a Schema.
+type Violation struct {
+	Path   binpack.Path
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%v: %s", v.Path, v.Reason)
+}
+
+// Violations is the error returned by Validate for data that breaks a
+// Schema, listing every violation.
+type Violations []*Violation
+
+func (v Violations) Error() string {
+	s := make([]string, len(v))
+	for i, e := range v {
+		s[i] = e.Error()
+	}
+	return "schema: " + strings.Join(s, "; ")
+}
+
+// Validate checks the single value encoded in data against s. It returns
+// Violations if the value breaks s, or the error of decoding malformed
+// data.
+func (s *Schema) Validate(data []byte) error {
+	var v binpack.Value
+	if err := v.UnmarshalBinpack(data); err != nil {
+		return err
+	}
+	if errs := s.ValidateValue(v); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateValue checks v against s and returns the violations found.
+func (s *Schema) ValidateValue(v binpack.Value) Violations {
+	var errs Violations
+	s.validate(v, nil, &errs)
+	return errs
+}
+
+func (s *Schema) validate(v binpack.Value, path binpack.Path, errs *Violations) {
+	if s == nil {
+		return
+	}
+	if len(s.Types) > 0 && !s.allows(v) {
+		errs.add(path, "%s is not of type %s", typeName(v), strings.Join(s.Types, " or "))
+		return
+	}
+
+	switch v.Kind() {
+	case binpack.Integer:
+		n := v.BigInt()
+		if s.Min != nil && n.Cmp(s.Min) < 0 {
+			errs.add(path, "%v is less than the minimum %v", n, s.Min)
+		}
+		if s.Max != nil && n.Cmp(s.Max) > 0 {
+			errs.add(path, "%v is greater than the maximum %v", n, s.Max)
+		}
+	case binpack.String:
+		if s.Pattern != nil && !s.Pattern.MatchString(v.Str()) {
+			errs.add(path, "%q does not match the pattern %s", v.Str(), s.Pattern)
+		}
+	case binpack.List:
+		for i := 0; i < v.Len(); i++ {
+			s.Elements.validate(v.Index(i), append(path, i), errs)
+		}
+	case binpack.Dict:
+		seen := make(map[string]bool)
+		for i := 0; i < v.Len(); i++ {
+			e := v.Entry(i)
+			if e.Key.Kind() != binpack.String {
+				if s.Closed {
+					errs.add(append(path, e.Key), "unexpected key")
+				}
+				continue
+			}
+			k := e.Key.Str()
+			seen[k] = true
+			required, isRequired := s.Keys[k]
+			optional, isOptional := s.Optional[k]
+			switch {
+			case isRequired:
+				required.validate(e.Value, append(path, k), errs)
+			case isOptional:
+				optional.validate(e.Value, append(path, k), errs)
+			case s.Closed:
+				errs.add(append(path, k), "unexpected key")
+			}
+		}
+		var missing []string
+		for k := range s.Keys {
+			if !seen[k] {
+				missing = append(missing, k)
+			}
+		}
+		sort.Strings(missing)
+		for _, k := range missing {
+			errs.add(append(path, k), "missing required key")
+		}
+	}
+
+	switch v.Kind() {
+	case binpack.String, binpack.Blob, binpack.List, binpack.Dict:
+		n := v.Len()
+		if v.Kind() == binpack.String {
+			n = utf8.RuneCountInString(v.Str())
+		}
+		if s.MinLength != nil && n < *s.MinLength {
+			errs.add(path, "length %d is less than the minimum %d", n, *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			errs.add(path, "length %d is greater than the maximum %d", n, *s.MaxLength)
+		}
+	}
+}
+
+// add adds a violation at a copy of path.
+func (v *Violations) add(path binpack.Path, format string, args ...interface{}) {
+	*v = append(*v, &Violation{
+		Path:   append(binpack.Path(nil), path...),
+		Reason: fmt.Sprintf(format, args...),
+	})
+}
+
+// typeNames maps the type names to the type they stand for.
+var typeNames = map[string]func(v binpack.Value) bool{
+	"nil":     kindIs(binpack.Nil),
+	"bool":    kindIs(binpack.True, binpack.False),
+	"byte":    intTypeIs(binpack.IntegerTypeByte),
+	"short":   intTypeIs(binpack.IntegerTypeShort),
+	"int":     intTypeIs(binpack.IntegerTypeInt),
+	"long":    intTypeIs(binpack.IntegerTypeLong),
+	"integer": kindIs(binpack.Integer),
+	"float":   kindIs(binpack.Float),
+	"double":  kindIs(binpack.Double),
+	"number":  kindIs(binpack.Integer, binpack.Float, binpack.Double),
+	"string":  kindIs(binpack.String),
+	"blob":    kindIs(binpack.Blob),
+	"list":    kindIs(binpack.List),
+	"dict":    kindIs(binpack.Dict),
+	"any":     func(binpack.Value) bool { return true },
+}
+
+func kindIs(codes ...binpack.Code) func(v binpack.Value) bool {
+	return func(v binpack.Value) bool {
+		for _, c := range codes {
+			if v.Kind() == c {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func intTypeIs(t binpack.Code) func(v binpack.Value) bool {
+	return func(v binpack.Value) bool {
+		return v.Kind() == binpack.Integer && v.IntType() == t
+	}
+}
+
+// allows reports whether v has one of the types of s.
+func (s *Schema) allows(v binpack.Value) bool {
+	for _, t := range s.Types {
+		if is := typeNames[t]; is != nil && is(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeName returns the most specific type name of v.
+func typeName(v binpack.Value) string {
+	switch v.Kind() {
+	case binpack.True, binpack.False:
+		return "bool"
+	case binpack.Integer:
+		for _, t := range []string{"byte", "short", "int", "long"} {
+			if typeNames[t](v) {
+				return t
+			}
+		}
+	}
+	return strings.ToLower(v.Kind().String())
+}
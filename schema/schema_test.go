@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/theodesp/binpack"
+)
+
+const userSchema = `{
+	"type": "dict",
+	"keys": {
+		"id": {"type": "long", "min": 1},
+		"name": {"type": "string", "minLength": 1, "maxLength": 5},
+		"tags": {"type": "list", "elements": {"type": "string", "pattern": "^[a-z]+$"}, "maxLength": 2}
+	},
+	"optional": {
+		"email": {"type": ["string", "nil"]},
+		"age": {"type": "integer", "min": 0, "max": 150},
+		"extra": null
+	},
+	"closed": true
+}`
+
+func mustParse(t *testing.T, notation string) []byte {
+	data, err := binpack.Parse(notation)
+	if err != nil {
+		t.Fatalf("binpack.Parse(%q): %v", notation, err)
+	}
+	return data
+}
+
+func TestValidate(t *testing.T) {
+	s, err := ParseJSON([]byte(strings.Replace(userSchema, `"extra": null`, `"extra": {}`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{`{"id": 1, "name": "ann", "tags": ["a"]}`, ""},
+		{`{"id": 7, "name": "ééééé", "tags": [], "email": nil, "age": 150i8, "extra": [h'00']}`, ""},
+		{`[]`, "schema: .: list is not of type dict"},
+		{`{"id": 0, "name": "", "tags": ["a", "B", "c"]}`,
+			"schema: .id: 0 is less than the minimum 1; .name: length 0 is less than the minimum 1; " +
+				`.tags[1]: "B" does not match the pattern ^[a-z]+$; .tags: length 3 is greater than the maximum 2`},
+		{`{"id": 1i32, "name": "ann", "email": 5, "age": -1, "other": 1, 5: 1}`,
+			"schema: .id: int is not of type long; .email: long is not of type string or nil; " +
+				".age: -1 is less than the minimum 0; .other: unexpected key; {5}: unexpected key; .tags: missing required key"},
+		{`{"id": 18446744073709551615, "name": "toolong", "tags": nil, "age": 151}`,
+			"schema: .name: length 7 is greater than the maximum 5; .tags: nil is not of type list; " +
+				".age: 151 is greater than the maximum 150"},
+	}
+	for _, test := range testCases {
+		err := s.Validate(mustParse(t, test.in))
+		got := ""
+		if err != nil {
+			if _, ok := err.(Violations); !ok {
+				t.Fatalf("schema:Validate(%s) got %T %v", test.in, err, err)
+			}
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Fatalf("schema:Validate(%s) got\n%s\nwanted\n%s", test.in, got, test.want)
+		}
+	}
+
+	if err := s.Validate([]byte{0x02}); err == nil {
+		t.Fatal("schema:Validate of malformed data succeeded")
+	}
+	if err := s.Validate(bytes.Repeat([]byte{byte(binpack.List)}, 1<<20)); err == nil {
+		t.Fatal("schema:Validate of deeply nested Lists succeeded")
+	}
+	if errs := new(Schema).ValidateValue(binpack.NewList(binpack.NewNil())); errs != nil {
+		t.Fatalf("schema:ValidateValue with the zero Schema got %v", errs)
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse(mustParse(t, `{"type": ["byte", "short"], "min": -5i8, "elements": {}, "optional": {"a": {"closed": false}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Types) != 2 || s.Min.Int64() != -5 || s.Max != nil || s.Elements == nil || s.Optional["a"] == nil || s.Keys != nil {
+		t.Fatalf("schema:Parse got %+v", s)
+	}
+
+	huge := `{"min": -18446744073709551615, "max": 18446744073709551615}`
+	if s, err = Parse(mustParse(t, huge)); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Describe().String(); got != huge {
+		t.Fatalf("schema:Describe got %s; wanted %s", got, huge)
+	}
+
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{`[]`, "schema: .: schema must be a Dict, not List"},
+		{`{"type": "str"}`, `schema: .type: unknown type "str"`},
+		{`{"type": ["nil", 1]}`, "schema: .type: unknown type 1"},
+		{`{"keys": {"a": {"elements": {"min": 1.5}}}}`, "schema: .keys.a.elements.min: Double is not an Integer"},
+		{`{"keys": {1: {}}}`, "schema: .keys: key 1 is not a String"},
+		{`{"optional": {"a": 1}}`, "schema: .optional.a: schema must be a Dict, not Integer"},
+		{`{"closed": 1}`, "schema: .closed: Integer is not a bool"},
+		{`{"maxLength": -1}`, "schema: .maxLength: -1 is not a length"},
+		{`{"pattern": "("}`, "schema: .pattern: error parsing regexp: missing closing ): `(`"},
+		{`{"required": {}}`, "schema: .required: unknown entry"},
+		{`{1: 2}`, "schema: .: key 1 is not a String"},
+	}
+	for _, test := range testCases {
+		_, err := Parse(mustParse(t, test.in))
+		if err == nil || err.Error() != test.want {
+			t.Fatalf("schema:Parse(%s) got error %v; wanted %s", test.in, err, test.want)
+		}
+	}
+	if _, err := ParseJSON([]byte(userSchema)); err == nil || err.Error() != "schema: .optional.extra: schema must be a Dict, not Nil" {
+		t.Fatalf("schema:ParseJSON got error %v", err)
+	}
+	if _, err := ParseJSON([]byte(`{"type":`)); err == nil {
+		t.Fatal("schema:ParseJSON of truncated JSON succeeded")
+	}
+}
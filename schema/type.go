@@ -0,0 +1,138 @@
+package schema
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/theodesp/binpack"
+	"github.com/theodesp/binpack/internal/structs"
+)
+
+var marshalerType = reflect.TypeOf((*binpack.Marshaler)(nil)).Elem()
+
+// FromType returns the Schema of the values binpack.Marshal writes for
+// values of type t:
+//
+//	bool                          bool
+//	int8, int16, int32, int64     byte, short, int and long, within the
+//	                              range of the Go type, as are the
+//	                              unsigned types
+//	float32, float64              float and double
+//	string                        string
+//	[]byte, [n]byte               blob
+//	slices and arrays             list of the schema of the element type
+//	maps                          dict
+//	structs                       dict with a key for every encoded field,
+//	                              optional if the field is omitempty
+//	pointers                      the schema of the element type, or nil
+//	interfaces, Marshalers        any value
+//
+// A type that refers to itself accepts any value where it is nested in
+// itself. FromType returns an error for types Marshal cannot encode, such
+// as channels and functions.
+func FromType(t reflect.Type) (*Schema, error) {
+	return fromType(t, make(map[reflect.Type]bool))
+}
+
+func fromType(t reflect.Type, seen map[reflect.Type]bool) (*Schema, error) {
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		return new(Schema), nil
+	}
+	if seen[t] {
+		return new(Schema), nil
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	s := new(Schema)
+	switch t.Kind() {
+	case reflect.Bool:
+		s.Types = []string{"bool"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		bits := uint(t.Bits())
+		s.Types = []string{intType(t)}
+		s.Min = new(big.Int).Lsh(big.NewInt(-1), bits-1)
+		s.Max = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bits-1), big.NewInt(1))
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		bits := uint(t.Bits())
+		s.Types = []string{intType(t)}
+		s.Min = new(big.Int)
+		s.Max = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bits), big.NewInt(1))
+	case reflect.Float32:
+		s.Types = []string{"float"}
+	case reflect.Float64:
+		s.Types = []string{"double"}
+	case reflect.String:
+		s.Types = []string{"string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s.Types = []string{"blob"}
+			if t.Kind() == reflect.Array {
+				n := t.Len()
+				s.MinLength, s.MaxLength = &n, &n
+			}
+			break
+		}
+		s.Types = []string{"list"}
+		elem, err := fromType(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		s.Elements = elem
+		if t.Kind() == reflect.Array {
+			n := t.Len()
+			s.MinLength, s.MaxLength = &n, &n
+		}
+	case reflect.Map:
+		s.Types = []string{"dict"}
+	case reflect.Struct:
+		s.Types = []string{"dict"}
+		if err := s.structFields(t, seen); err != nil {
+			return nil, err
+		}
+	case reflect.Ptr:
+		elem, err := fromType(t.Elem(), seen)
+		if err != nil || len(elem.Types) == 0 {
+			return elem, err
+		}
+		elem.Types = append(elem.Types, "nil")
+		return elem, nil
+	case reflect.Interface:
+	default:
+		return nil, fmt.Errorf("schema: unsupported type %v", t)
+	}
+	return s, nil
+}
+
+// intType returns the name of the Integer subtype Marshal writes for the
+// integer type t. int and uint are written as Longs whatever their size.
+func intType(t reflect.Type) string {
+	if t.Kind() == reflect.Int || t.Kind() == reflect.Uint {
+		return "long"
+	}
+	return map[int]string{8: "byte", 16: "short", 32: "int", 64: "long"}[t.Bits()]
+}
+
+// structFields adds the keys of the fields of the struct type t to s.
+func (s *Schema) structFields(t reflect.Type, seen map[reflect.Type]bool) error {
+	for _, f := range structs.Fields(t) {
+		sf := t.FieldByIndex(f.Index)
+		fs, err := fromType(sf.Type, seen)
+		if err != nil {
+			return fmt.Errorf("%v, in field %s of %v", err, sf.Name, t)
+		}
+		if f.OmitEmpty {
+			if s.Optional == nil {
+				s.Optional = make(map[string]*Schema)
+			}
+			s.Optional[f.Name] = fs
+		} else {
+			if s.Keys == nil {
+				s.Keys = make(map[string]*Schema)
+			}
+			s.Keys[f.Name] = fs
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/theodesp/binpack"
+)
+
+type testItem struct {
+	ID    int64    `binpack:"id"`
+	Price *float32 `binpack:"price,omitempty"`
+}
+
+type testOrder struct {
+	ID     uint16     `binpack:"id"`
+	Items  []testItem `binpack:"items"`
+	Note   string     `binpack:"note,omitempty"`
+	Data   [2]byte
+	Meta   map[string]string  `binpack:"meta"`
+	Any    interface{}        `binpack:"any"`
+	Raw    binpack.RawMessage `binpack:"raw"`
+	Skip   int                `binpack:"-"`
+	hidden int
+	Next   *testOrder `binpack:"next"`
+}
+
+func TestFromType(t *testing.T) {
+	s, err := FromType(reflect.TypeOf(testOrder{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type": "dict", "keys": {"Data": {"type": "blob", "minLength": 2, "maxLength": 2}, "any": {}, ` +
+		`"id": {"type": "short", "min": 0, "max": 65535}, "items": {"type": "list", "elements": {"type": "dict", ` +
+		`"keys": {"id": {"type": "long", "min": -9223372036854775808, "max": 9223372036854775807}}, ` +
+		`"optional": {"price": {"type": ["float", "nil"]}}}}, "meta": {"type": "dict"}, "next": {}, "raw": {}}, ` +
+		`"optional": {"note": {"type": "string"}}}`
+	if got := s.Describe().String(); got != want {
+		t.Fatalf("schema:FromType got\n%s\nwanted\n%s", got, want)
+	}
+
+	data, err := s.MarshalBinpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed Schema
+	if err := binpack.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Describe().String(); got != want {
+		t.Fatalf("schema:Parse of the description got\n%s", got)
+	}
+
+	price := float32(1.5)
+	order := testOrder{ID: 7, Items: []testItem{{1, &price}, {-1, nil}}, Raw: binpack.RawMessage{0x41}, Next: &testOrder{}}
+	data, err = binpack.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(data); err != nil {
+		t.Fatalf("schema:Validate of a marshalled testOrder got %v", err)
+	}
+
+	for _, test := range []struct {
+		v    interface{}
+		want string
+	}{
+		{make(chan int), "schema: unsupported type chan int"},
+		{struct{ F func() }{}, "schema: unsupported type func(), in field F of struct { F func() }"},
+	} {
+		if _, err := FromType(reflect.TypeOf(test.v)); err == nil || err.Error() != test.want {
+			t.Fatalf("schema:FromType(%T) got error %v; wanted %s", test.v, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package binpack
+
+import (
+	"bufio"
+	"io"
+	"sort"
+)
+
+// Stats summarizes the values of one or more streams. The zero Stats is
+// empty and ready to use.
+type Stats struct {
+	// Values is the number of top-level values and Bytes their total size.
+	Values int
+	Bytes  int64
+	// Codes counts the values of every type, nested values and Dict keys
+	// included. Integers are counted with their subtype, as in
+	// Integer|IntegerTypeByte.
+	Codes map[Code]int
+	// StringLengths and BlobLengths are the distributions of the lengths of
+	// Strings and Blobs.
+	StringLengths LengthHistogram
+	BlobLengths   LengthHistogram
+	// MaxDepth is the deepest nesting of Lists and Dicts: 0 when there are
+	// only scalars, 1 when no List or Dict holds another one.
+	MaxDepth int
+	// Keys counts the Dict keys at any depth by their notation, such as
+	// "id" or 5i8.
+	Keys map[string]int
+	// PathBytes holds the size of the entries of top-level Dicts, key
+	// included, by the path of the entry, such as .users. The other
+	// top-level values and the header and Closure of top-level Dicts are
+	// counted under ".", so that PathBytes adds up to Bytes.
+	PathBytes map[string]int64
+}
+
+// A LengthHistogram is a distribution of lengths in power of two buckets:
+// Buckets[0] counts the empty values and Buckets[i] the lengths from
+// 2^(i-1) to 2^i-1.
+type LengthHistogram struct {
+	Count   int
+	Total   int64
+	Max     int
+	Buckets []int
+}
+
+func (h *LengthHistogram) add(n int) {
+	h.Count++
+	h.Total += int64(n)
+	if n > h.Max {
+		h.Max = n
+	}
+	i := 0
+	for ; n > 0; n >>= 1 {
+		i++
+	}
+	for len(h.Buckets) <= i {
+		h.Buckets = append(h.Buckets, 0)
+	}
+	h.Buckets[i]++
+}
+
+// A KeyCount is a Dict key and how often it was seen.
+type KeyCount struct {
+	Key   string
+	Count int
+}
+
+// CollectStats returns the statistics of the stream of concatenated values
+// read from r.
+func CollectStats(r io.Reader) (*Stats, error) {
+	s := new(Stats)
+	if _, err := s.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ReadFrom adds the values of the stream read from r to s, one value at a
+// time, and returns the number of bytes of the values. Values read before
+// an error are counted.
+func (s *Stats) ReadFrom(r io.Reader) (n int64, err error) {
+	if s.Codes == nil {
+		s.Codes = make(map[Code]int)
+		s.Keys = make(map[string]int)
+		s.PathBytes = make(map[string]int64)
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<30)
+	sc.Split(ScanValues)
+	for sc.Scan() {
+		if err := s.add(sc.Bytes()); err != nil {
+			return n, err
+		}
+		n += int64(len(sc.Bytes()))
+	}
+	return n, sc.Err()
+}
+
+// TopKeys returns the n most frequent Dict keys, most frequent first.
+func (s *Stats) TopKeys(n int) []KeyCount {
+	keys := make([]KeyCount, 0, len(s.Keys))
+	for k, c := range s.Keys {
+		keys = append(keys, KeyCount{k, c})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// add adds the single value encoded in data.
+func (s *Stats) add(data []byte) (err error) {
+	defer catchError(&err)
+	d := decodeState{data: data}
+	h := s.value(&d, 0)
+	s.Values++
+	s.Bytes += int64(len(data))
+	if h.code != Dict {
+		s.PathBytes["."] += int64(len(data))
+		return nil
+	}
+	// Walk the entries again to attribute their bytes to their keys.
+	d.off = h.size
+	s.PathBytes["."] += int64(h.size + 1)
+	for !d.closure() {
+		start := d.off
+		k := d.tree()
+		d.skip()
+		s.PathBytes[Path{pathElem(k)}.String()] += int64(d.off - start)
+	}
+	return nil
+}
+
+// value counts the next value, which is nested depth Lists and Dicts deep,
+// and returns its header.
+func (s *Stats) value(d *decodeState, depth int) header {
+	h := d.header()
+	switch h.code {
+	case List, Dict:
+		if depth >= maxDepth {
+			error_(syntaxErrorf(d.off-h.size, "exceeded max depth of %d", maxDepth))
+		}
+		s.Codes[h.code]++
+		if depth+1 > s.MaxDepth {
+			s.MaxDepth = depth + 1
+		}
+		for !d.closure() {
+			if h.code == Dict {
+				start := d.off
+				s.value(d, depth+1)
+				k := (&decodeState{data: d.data, off: start}).tree()
+				s.Keys[k.String()]++
+			}
+			s.value(d, depth+1)
+		}
+	case Integer:
+		s.Codes[Integer|h.intType()]++
+	case String:
+		s.Codes[String]++
+		s.StringLengths.add(len(d.payload(h)))
+	case Blob:
+		s.Codes[Blob]++
+		s.BlobLengths.add(len(d.payload(h)))
+	case Closure:
+		error_(syntaxErrorf(d.off-1, "unexpected Closure"))
+	default:
+		s.Codes[h.code]++
+		d.payload(h)
+	}
+	return h
+}
@@ -0,0 +1,81 @@
+package binpack
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCollectStats(t *testing.T) {
+	var in bytes.Buffer
+	for _, s := range []string{
+		`{"id": 1, "name": "ann", "tags": ["a", "bc"]}`,
+		`{"id": 200i8, "name": "", "blob": h'0102030405'}`,
+		`[[[nil]]] 1.5f`,
+	} {
+		data, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in.Write(data)
+	}
+	s, err := CollectStats(bytes.NewReader(in.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Values != 4 || s.Bytes != int64(in.Len()) || s.MaxDepth != 3 {
+		t.Fatalf("binpack:CollectStats got %d values, %d bytes, depth %d", s.Values, s.Bytes, s.MaxDepth)
+	}
+	wantCodes := map[Code]int{
+		Dict: 2, List: 4, String: 10, Blob: 1, Nil: 1, Float: 1,
+		Integer | IntegerTypeLong: 1, Integer | IntegerTypeByte: 1,
+	}
+	if !reflect.DeepEqual(s.Codes, wantCodes) {
+		t.Fatalf("binpack:CollectStats got codes %v; wanted %v", s.Codes, wantCodes)
+	}
+	wantStrings := LengthHistogram{Count: 10, Total: 26, Max: 4, Buckets: []int{1, 1, 4, 4}}
+	if !reflect.DeepEqual(s.StringLengths, wantStrings) {
+		t.Fatalf("binpack:CollectStats got String lengths %+v; wanted %+v", s.StringLengths, wantStrings)
+	}
+	wantBlobs := LengthHistogram{Count: 1, Total: 5, Max: 5, Buckets: []int{0, 0, 0, 1}}
+	if !reflect.DeepEqual(s.BlobLengths, wantBlobs) {
+		t.Fatalf("binpack:CollectStats got Blob lengths %+v; wanted %+v", s.BlobLengths, wantBlobs)
+	}
+	wantKeys := []KeyCount{{`"id"`, 2}, {`"name"`, 2}, {`"blob"`, 1}}
+	if got := s.TopKeys(3); !reflect.DeepEqual(got, wantKeys) {
+		t.Fatalf("binpack:Stats.TopKeys got %v; wanted %v", got, wantKeys)
+	}
+	wantPaths := map[string]int64{".": 2 + 2 + 7 + 5, ".id": 4 + 5, ".name": 9 + 6, ".tags": 12, ".blob": 11}
+	if !reflect.DeepEqual(s.PathBytes, wantPaths) {
+		t.Fatalf("binpack:CollectStats got path bytes %v; wanted %v", s.PathBytes, wantPaths)
+	}
+	var total int64
+	for _, n := range s.PathBytes {
+		total += n
+	}
+	if total != s.Bytes {
+		t.Fatalf("binpack:CollectStats path bytes add up to %d of %d", total, s.Bytes)
+	}
+}
+
+func TestStats_ReadFrom(t *testing.T) {
+	var s Stats
+	for i := 0; i < 2; i++ {
+		if n, err := s.ReadFrom(bytes.NewReader([]byte{0x41, 0x0f})); err != nil || n != 2 {
+			t.Fatalf("binpack:Stats.ReadFrom got %d, %v", n, err)
+		}
+	}
+	if s.Values != 4 || s.Codes[Nil] != 2 {
+		t.Fatalf("binpack:Stats.ReadFrom got %d values, %d Nils", s.Values, s.Codes[Nil])
+	}
+
+	for _, in := range [][]byte{{0x41, 0x02, 0x41}, {0x21, 0x61, 0x08}, {0x03, 0x41, 0x01}} {
+		if _, err := s.ReadFrom(bytes.NewReader(in)); err == nil {
+			t.Fatalf("binpack:Stats.ReadFrom(%x) succeeded", in)
+		}
+	}
+	deep := append(bytes.Repeat([]byte{0x02}, 1<<20), bytes.Repeat([]byte{0x01}, 1<<20)...)
+	if _, err := s.ReadFrom(bytes.NewReader(deep)); err == nil {
+		t.Fatal("binpack:Stats.ReadFrom of deeply nested Lists succeeded")
+	}
+}
@@ -0,0 +1,43 @@
+package binpack
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/theodesp/binpack/internal/structs"
+)
+
+// fieldByName returns the field of fields named name, or failing that the
+// first one whose name matches without regard to case.
+func fieldByName(fields []structs.Field, name []byte) *structs.Field {
+	for i := range fields {
+		if fields[i].Name == string(name) {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].Name, string(name)) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// isEmptyValue reports whether v is left out by omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
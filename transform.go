@@ -0,0 +1,182 @@
+package binpack
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// An Action tells Transform what to do with a value.
+type Action int
+
+const (
+	// Keep writes the value unchanged. The elements of a kept List or Dict
+	// are transformed in turn.
+	Keep Action = iota
+	// Replace writes the returned RawMessage, which must hold exactly one
+	// value, instead of the value. A nil RawMessage writes Nil.
+	Replace
+	// Drop leaves the value out. Dropping the value of a Dict entry drops
+	// its key too.
+	Drop
+	// Truncate writes an empty value of the same type: an empty String,
+	// Blob, List or Dict, or a zero Integer, Float or Double. Nil, True and
+	// False are written unchanged.
+	Truncate
+)
+
+// Transform copies the stream of values read from r to w, calling fn for
+// every value on the way to decide what to write for it. Dict keys are not
+// passed to fn, but appear in the path of their value. List indexes in the
+// path count the values read, including dropped ones.
+//
+// For a List or Dict fn is called with its header byte only, before its
+// elements are read, and the elements of a kept List or Dict are passed to
+// fn after it. Other values are passed whole. Both the path and the
+// RawMessage are only valid during the call to fn.
+//
+// Lists and Dicts are streamed, so memory use is bounded by the largest
+// String or Blob, not by the size of the stream.
+func Transform(r io.Reader, w io.Writer, fn func(path Path, v RawMessage) (RawMessage, Action)) error {
+	t := transformer{dec: NewDecoder(r), w: bufio.NewWriter(w), fn: fn}
+	for {
+		h, err := t.dec.nextHeader()
+		if err == io.EOF {
+			break
+		}
+		if err == nil && h.code == Closure {
+			err = syntaxErrorf(int(t.dec.off), "unexpected Closure")
+		}
+		if err == nil {
+			err = t.value(h, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return t.w.Flush()
+}
+
+// transformer holds the state of Transform.
+type transformer struct {
+	dec  *Decoder
+	w    *bufio.Writer
+	fn   func(path Path, v RawMessage) (RawMessage, Action)
+	path Path
+}
+
+// value transforms the value whose header h has just been read, writing
+// prefix before it unless it is dropped.
+func (t *transformer) value(h header, prefix []byte) error {
+	container := h.code == List || h.code == Dict
+	if !container {
+		if err := t.dec.readPayload(h.payload()); err != nil {
+			return err
+		}
+	}
+	out, action := t.fn(t.path, t.dec.buf.Bytes())
+	switch action {
+	case Keep:
+		if container && len(t.path) >= maxDepth {
+			return syntaxErrorf(int(t.dec.off), "exceeded max depth of %d", maxDepth)
+		}
+		t.write(prefix, t.dec.buf.Bytes())
+		if container {
+			return t.elements(h.code)
+		}
+		return nil
+	case Replace:
+		if out == nil {
+			out = RawMessage{byte(Nil)}
+		}
+		if n, err := Next(out); err != nil || n != len(out) {
+			return errors.New("binpack: Transform replacement is not a single value")
+		}
+		t.write(prefix, out)
+	case Truncate:
+		t.write(prefix, emptyValue(h))
+	}
+	if container {
+		t.dec.off += int64(t.dec.buf.Len())
+		t.dec.buf.Reset()
+		return t.dec.scanRest(1, false)
+	}
+	return nil
+}
+
+// elements transforms the elements of a kept List or Dict up to its
+// Closure.
+func (t *transformer) elements(code Code) error {
+	for i := 0; ; i++ {
+		h, err := t.dec.innerHeader()
+		if err != nil {
+			return err
+		}
+		if h.code == Closure {
+			break
+		}
+		var key []byte
+		if code == List {
+			t.path = append(t.path, i)
+		} else {
+			if key, err = t.key(h); err != nil {
+				return err
+			}
+			if h, err = t.dec.innerHeader(); err != nil {
+				return err
+			}
+			if h.code == Closure {
+				return syntaxErrorf(int(t.dec.off), "Dict with a key but no value")
+			}
+		}
+		err = t.value(h, key)
+		t.path = t.path[:len(t.path)-1]
+		if err != nil {
+			return err
+		}
+	}
+	t.write(nil, []byte{byte(Closure)})
+	return nil
+}
+
+// key reads the rest of a Dict key whose header h has just been read,
+// adds it to the path and returns a copy of its encoding.
+func (t *transformer) key(h header) ([]byte, error) {
+	var err error
+	if h.code == List || h.code == Dict {
+		err = t.dec.scanRest(1, true)
+		t.dec.off -= int64(t.dec.buf.Len())
+	} else {
+		err = t.dec.readPayload(h.payload())
+	}
+	if err != nil {
+		return nil, err
+	}
+	key := append([]byte(nil), t.dec.buf.Bytes()...)
+	var k Value
+	if err := k.UnmarshalBinpack(key); err != nil {
+		return nil, err
+	}
+	t.path = append(t.path, pathElem(k))
+	return key, nil
+}
+
+// write writes prefix and b. Errors are reported by the final Flush, as
+// a bufio.Writer keeps failing once a write has failed.
+func (t *transformer) write(prefix, b []byte) {
+	t.w.Write(prefix)
+	t.w.Write(b)
+}
+
+// emptyValue returns the encoding of the empty value of the type of h.
+func emptyValue(h header) []byte {
+	switch h.code {
+	case List, Dict:
+		return []byte{byte(h.code), byte(Closure)}
+	case Integer:
+		return []byte{byte(Integer | h.intType())}
+	case Float, Double:
+		return append([]byte{byte(h.code)}, make([]byte, h.payload())...)
+	}
+	return []byte{byte(h.code)}
+}
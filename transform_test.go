@@ -0,0 +1,151 @@
+package binpack
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func transformText(t *testing.T, in string, fn func(Path, RawMessage) (RawMessage, Action)) (string, error) {
+	data, err := Parse(in)
+	if err != nil {
+		t.Fatalf("binpack:Parse(%s) error %v", in, err)
+	}
+	var out bytes.Buffer
+	err = Transform(bytes.NewReader(data), &out, fn)
+	return Format(out.Bytes()), err
+}
+
+func TestTransform(t *testing.T) {
+	scrub := func(path Path, v RawMessage) (RawMessage, Action) {
+		if len(path) == 0 {
+			return nil, Keep
+		}
+		// Scrub PII fields at any depth.
+		switch path[len(path)-1] {
+		case "email":
+			return nil, Drop
+		case "token":
+			return nil, Truncate
+		}
+		return nil, Keep
+	}
+	testCases := []struct {
+		in, want string
+		fn       func(Path, RawMessage) (RawMessage, Action)
+	}{
+		{
+			`{"id": 1, "email": "a@b.c", "user": {"token": "s3cr3t", "emails": ["x"], "email": {"a": 1}}, "token": [1, 2]}` + "\n" + `[{"email": nil}] "email"`,
+			`{"id": 1, "user": {"token": "", "emails": ["x"]}, "token": []}` + "\n" + `[{}]` + "\n" + `"email"`,
+			scrub,
+		},
+		{
+			`[1, 2, 3, [4, 5]] 6`,
+			`[1, 3, []]`,
+			func(path Path, v RawMessage) (RawMessage, Action) {
+				switch {
+				case len(path) == 0 && v[0] != byte(List):
+					return nil, Drop
+				case len(path) == 1 && path[0] == 1:
+					return nil, Drop
+				case len(path) == 1 && path[0] == 3:
+					return nil, Truncate
+				}
+				return nil, Keep
+			},
+		},
+		{
+			`{"a": [1, 2], "b": h'01', 2: 3} {3i8: "x"}`,
+			`{"a": "A", "b": nil, 2: 3}` + "\n" + `{3i8: "y"}`,
+			func(path Path, v RawMessage) (RawMessage, Action) {
+				if len(path) == 1 && path[0] == "a" {
+					return RawMessage{0x21, 'A'}, Replace
+				}
+				if len(path) == 1 && path[0] == "b" {
+					return nil, Replace
+				}
+				if path.String() == "{3i8}" {
+					return RawMessage{0x21, 'y'}, Replace
+				}
+				return nil, Keep
+			},
+		},
+		{
+			`[-5i16, 1.5, 2.5f, "abc", h'01', nil, true, false, {1: 2}, [[1]]]`,
+			`[0i16, 0.0, 0f, "", h'', nil, true, false, {}, []]`,
+			func(path Path, v RawMessage) (RawMessage, Action) {
+				if len(path) == 1 {
+					return nil, Truncate
+				}
+				return nil, Keep
+			},
+		},
+	}
+	for _, test := range testCases {
+		got, err := transformText(t, test.in, test.fn)
+		if err != nil || got != test.want {
+			t.Fatalf("binpack:Transform(%s) got %s, %v; wanted %s", test.in, got, err, test.want)
+		}
+	}
+}
+
+func TestTransform_Errors(t *testing.T) {
+	keep := func(Path, RawMessage) (RawMessage, Action) { return nil, Keep }
+	testCases := []struct {
+		in  []byte
+		fn  func(Path, RawMessage) (RawMessage, Action)
+		err string
+	}{
+		{[]byte{0x02, 0x41}, keep, io.ErrUnexpectedEOF.Error()},
+		{[]byte{0x03, 0x21, 0x61}, keep, io.ErrUnexpectedEOF.Error()},
+		{[]byte{0x03, 0x21, 0x61, 0x01}, keep, "Dict with a key but no value"},
+		{[]byte{0x25, 0x61}, keep, io.ErrUnexpectedEOF.Error()},
+		{[]byte{0x01}, keep, "unexpected Closure"},
+		{[]byte{0x02, 0x00, 0x01}, keep, "invalid code"},
+		{bytes.Repeat([]byte{0x02}, 1<<20), keep, "exceeded max depth"},
+		{[]byte{0x02, 0x02, 0x41}, func(path Path, _ RawMessage) (RawMessage, Action) {
+			if len(path) == 1 {
+				return nil, Drop
+			}
+			return nil, Keep
+		}, io.ErrUnexpectedEOF.Error()},
+		{[]byte{0x41}, func(Path, RawMessage) (RawMessage, Action) {
+			return RawMessage{0x41, 0x41}, Replace
+		}, "replacement is not a single value"},
+	}
+	for _, test := range testCases {
+		err := Transform(bytes.NewReader(test.in), ioutil.Discard, test.fn)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("binpack:Transform(%.20x) got error %v; wanted %s", test.in, err, test.err)
+		}
+	}
+
+	data, _ := Marshal([]int{1, 2, 3})
+	if err := Transform(bytes.NewReader(data), errorWriter{}, keep); err == nil {
+		t.Fatal("binpack:Transform to a failing writer expected error: got none")
+	}
+}
+
+// TestTransform_Streaming checks that dropped Lists are skipped without
+// being buffered, by dropping one far larger than the transformer buffers.
+func TestTransform_Streaming(t *testing.T) {
+	big := make([]string, 1<<12)
+	for i := range big {
+		big[i] = strings.Repeat("x", 1<<10)
+	}
+	data, _ := Marshal(map[string]interface{}{"big": big})
+	var out bytes.Buffer
+	calls := 0
+	err := Transform(bytes.NewReader(data), &out, func(path Path, v RawMessage) (RawMessage, Action) {
+		calls++
+		if len(path) == 1 {
+			return nil, Drop
+		}
+		return nil, Keep
+	})
+	if err != nil || calls != 2 || Format(out.Bytes()) != "{}" {
+		t.Fatalf("binpack:Transform got %s, %d calls, %v; wanted {} and 2 calls", Format(out.Bytes()), calls, err)
+	}
+}
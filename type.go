@@ -1,5 +1,7 @@
 package binpack
 
+import "fmt"
+
 type Code byte
 
 const (
@@ -30,6 +32,9 @@ const (
 	MaskTypeStringOrBlob Code = 0x30 /* 00xx 0000: string or blob */
 	MaskLastInteger      Code = 0x1f /* 000x xxxx the last 5 bits */
 	MaskLastUintLen      Code = 0x0f /* 0000 xxxx the last 4 bits will be used to pack unit len */
+	MaskIntegerType      Code = 0x18 /* xxx1 1xxx the integer subtype */
+
+	maskLastIntegerBits Code = 0x07 /* xxxx xvvv the value bits left in the last byte of an integer */
 
 	TagPackNumber  Code = 0x0f // 0001 xxxx
 	TagPackInteger Code = 0x20 // 000x xxxx
@@ -38,3 +43,33 @@ const (
 	NumSignBit Code = 0x80 // 1000 0000
 	NumMask    Code = 0x7f // 0111 1111
 )
+
+var codeNames = map[Code]string{
+	Closure: "Closure",
+	List:    "List",
+	Dict:    "Dict",
+	True:    "True",
+	False:   "False",
+	Double:  "Double",
+	Float:   "Float",
+	Nil:     "Nil",
+}
+
+// String returns the name of the value type a code byte stands for, such as
+// "List" for 0x02 or "String" for any of 0x20-0x2f.
+func (c Code) String() string {
+	switch {
+	case c&NumSignBit != 0:
+		return "Continuation"
+	case c&Integer != 0:
+		return "Integer"
+	case c&String != 0 && c&Blob == 0:
+		return "String"
+	case c&String == 0 && c&Blob != 0:
+		return "Blob"
+	}
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Code(0x%02x)", byte(c))
+}
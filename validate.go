@@ -0,0 +1,130 @@
+package binpack
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// ValidateOptions configures Validate.
+type ValidateOptions struct {
+	// UTF8 reports Strings that are not valid UTF-8.
+	UTF8 bool
+}
+
+// A ValidationError describes a problem found by Validate.
+type ValidationError struct {
+	Offset int64 // offset of the offending bytes in the data
+	Path   Path  // path of the offending value within its document
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("binpack: %s at offset %d (path %v)", e.Reason, e.Offset, e.Path)
+}
+
+// Validate checks that data is a well-formed sequence of values: every
+// List and Dict ends with a Closure, every Dict key has a value, no
+// payload is cut short, no reserved code such as 0x08 to 0x0e is used and
+// Lists and Dicts are nested no deeper than Unmarshal accepts. It returns
+// nil if data is valid.
+//
+// A malformed header or a truncated value leaves the rest of data
+// unreadable, so Validate stops at the first one. Invalid UTF-8 in
+// Strings, reported when opts.UTF8 is set, does not stop it.
+func Validate(data []byte, opts ValidateOptions) []*ValidationError {
+	v := validator{data: data, opts: opts}
+	for off := 0; off < len(data); {
+		var err *ValidationError
+		if off, err = v.value(off); err != nil {
+			v.errs = append(v.errs, err)
+			break
+		}
+	}
+	return v.errs
+}
+
+// validator holds the state of Validate.
+type validator struct {
+	data []byte
+	opts ValidateOptions
+	path Path
+	errs []*ValidationError
+}
+
+// fail returns a ValidationError for the bytes at off.
+func (v *validator) fail(off int, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{
+		Offset: int64(off),
+		Path:   append(Path(nil), v.path...),
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+// value checks the value starting at off and returns the offset following
+// it.
+func (v *validator) value(off int) (int, *ValidationError) {
+	h, err := readHeader(v.data, off)
+	switch err := err.(type) {
+	case nil:
+	case *SyntaxError:
+		if c := v.data[err.Offset]; c >= 0x08 && c <= 0x0e {
+			return 0, v.fail(int(err.Offset), "reserved code 0x%02x", c)
+		}
+		return 0, v.fail(int(err.Offset), "%s", err.msg)
+	default:
+		return 0, v.fail(off, "truncated header")
+	}
+	start := off
+	off += h.size
+	switch h.code {
+	case Closure:
+		return 0, v.fail(start, "unexpected Closure")
+	case List, Dict:
+		if len(v.path) >= maxDepth {
+			return 0, v.fail(start, "%v nested deeper than %d", h.code, maxDepth)
+		}
+		return v.elements(h.code, start, off)
+	}
+	n := h.payload()
+	if avail := uint64(len(v.data) - off); n > avail {
+		return 0, v.fail(start, "%v payload truncated: %d of %d bytes", h.code, avail, n)
+	}
+	end := off + int(n)
+	if h.code == String && v.opts.UTF8 && !utf8.Valid(v.data[off:end]) {
+		v.errs = append(v.errs, v.fail(start, "invalid UTF-8 in String"))
+	}
+	return end, nil
+}
+
+// elements checks the elements of the List or Dict starting at start up to
+// its Closure, from off on.
+func (v *validator) elements(code Code, start, off int) (int, *ValidationError) {
+	for i := 0; ; i++ {
+		if off == len(v.data) {
+			return 0, v.fail(off, "missing Closure for %v at offset %d", code, start)
+		}
+		if Code(v.data[off]) == Closure {
+			return off + 1, nil
+		}
+		if code == List {
+			v.path = append(v.path, i)
+		} else {
+			k := off
+			var err *ValidationError
+			if off, err = v.value(off); err != nil {
+				return 0, err
+			}
+			key, _ := (&decodeState{data: v.data[k:off]}).nextTree()
+			if off == len(v.data) || Code(v.data[off]) == Closure {
+				return 0, v.fail(off, "Dict key without a value")
+			}
+			v.path = append(v.path, pathElem(key))
+		}
+		var err *ValidationError
+		off, err = v.value(off)
+		v.path = v.path[:len(v.path)-1]
+		if err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package binpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"0321610241420101410f", ""},
+		{"0222ff6101", ""},
+		{"0241", "binpack: missing Closure for List at offset 0 at offset 2 (path .)"},
+		{"02410a01", "binpack: reserved code 0x0a at offset 2 (path [1])"},
+		{"0f0321610241", "binpack: missing Closure for List at offset 4 at offset 6 (path .a)"},
+		{"03216101", "binpack: Dict key without a value at offset 3 (path .)"},
+		{"032161", "binpack: Dict key without a value at offset 3 (path .)"},
+		{"02410101", "binpack: unexpected Closure at offset 3 (path .)"},
+		{"03216107", "binpack: Float payload truncated: 0 of 4 bytes at offset 3 (path .a)"},
+		{"2361", "binpack: String payload truncated: 1 of 3 bytes at offset 0 (path .)"},
+		{"80", "binpack: truncated header at offset 0 (path .)"},
+		{"8004", "binpack: True cannot follow length continuation bytes at offset 1 (path .)"},
+		{"30", "binpack: invalid code 0x30 at offset 0 (path .)"},
+	}
+	for _, test := range testCases {
+		in, _ := hex.DecodeString(test.in)
+		errs := Validate(in, ValidateOptions{})
+		got := ""
+		if len(errs) > 1 {
+			t.Fatalf("binpack:Validate(%s) got %d errors: %v", test.in, len(errs), errs)
+		} else if len(errs) == 1 {
+			got = errs[0].Error()
+		}
+		if got != test.want {
+			t.Fatalf("binpack:Validate(%s) got %q; wanted %q", test.in, got, test.want)
+		}
+	}
+
+	deep := bytes.Repeat([]byte{byte(List)}, 1<<20)
+	errs := Validate(deep, ValidateOptions{})
+	if len(errs) != 1 {
+		t.Fatalf("binpack:Validate of deeply nested Lists got %d errors", len(errs))
+	}
+	if errs[0].Offset != maxDepth || errs[0].Reason != "List nested deeper than 10000" {
+		t.Fatalf("binpack:Validate of deeply nested Lists got %q at offset %d", errs[0].Reason, errs[0].Offset)
+	}
+}
+
+func TestValidate_UTF8(t *testing.T) {
+	in, _ := hex.DecodeString("0222ff6122c3a90121802202")
+	errs := Validate(in, ValidateOptions{UTF8: true})
+	want := []string{
+		"binpack: invalid UTF-8 in String at offset 1 (path [0])",
+		"binpack: invalid UTF-8 in String at offset 8 (path .)",
+		"binpack: String payload truncated: 1 of 2 bytes at offset 10 (path .)",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("binpack:Validate got %v; wanted %v", errs, want)
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Fatalf("binpack:Validate got %q; wanted %q", err, want[i])
+		}
+	}
+}
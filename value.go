@@ -0,0 +1,410 @@
+package binpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/big"
+)
+
+// A Value is a decoded binpack value that keeps every detail of its
+// encoding: Float and Double stay distinct, Integers remember their subtype
+// and Blobs stay separate from Strings. Encoding a Value that was decoded
+// from some data reproduces that data, except that headers are written in
+// their shortest form: the Integer 8140, whose high continuation bits are
+// all zero, encodes again as 41, and 8060 as 60.
+//
+// The zero Value is Nil. Methods that only apply to some kinds of values
+// panic when called on another kind, like those of reflect.Value.
+type Value struct {
+	code  Code    // Nil, True, False, Integer, Float, Double, String, Blob, List or Dict
+	itype Code    // integer subtype
+	neg   bool    // negative integer
+	n     uint64  // integer magnitude, or the bits of a Float or Double
+	s     string  // String payload
+	b     []byte  // Blob payload
+	list  []Value // List elements
+	dict  []Entry // Dict entries, in encoding order
+}
+
+// An Entry is a key and value pair of a Dict.
+type Entry struct {
+	Key   Value
+	Value Value
+}
+
+// NewNil returns a Nil Value.
+func NewNil() Value {
+	return Value{code: Nil}
+}
+
+// NewBool returns a True or False Value.
+func NewBool(b bool) Value {
+	if b {
+		return Value{code: True}
+	}
+	return Value{code: False}
+}
+
+// NewInt returns an Integer Value with subtype typ, which is one of
+// IntegerTypeByte, IntegerTypeShort, IntegerTypeInt or IntegerTypeLong.
+func NewInt(i int64, typ Code) Value {
+	if i < 0 {
+		return Value{code: Integer, itype: typ & MaskIntegerType, neg: true, n: uint64(-i)}
+	}
+	return Value{code: Integer, itype: typ & MaskIntegerType, n: uint64(i)}
+}
+
+// NewUint returns a non-negative Integer Value with subtype typ.
+func NewUint(u uint64, typ Code) Value {
+	return Value{code: Integer, itype: typ & MaskIntegerType, n: u}
+}
+
+// NewBigInt returns an Integer Value with subtype typ. It panics if the
+// magnitude of i does not fit a uint64.
+func NewBigInt(i *big.Int, typ Code) Value {
+	n := new(big.Int).Abs(i)
+	if !n.IsUint64() {
+		panic("binpack: NewBigInt of Integer overflowing uint64 magnitude")
+	}
+	return Value{code: Integer, itype: typ & MaskIntegerType, neg: i.Sign() < 0, n: n.Uint64()}
+}
+
+// NewFloat returns a single precision Float Value.
+func NewFloat(f float32) Value {
+	return Value{code: Float, n: uint64(math.Float32bits(f))}
+}
+
+// NewDouble returns a double precision Double Value.
+func NewDouble(f float64) Value {
+	return Value{code: Double, n: math.Float64bits(f)}
+}
+
+// NewString returns a String Value.
+func NewString(s string) Value {
+	return Value{code: String, s: s}
+}
+
+// NewBlob returns a Blob Value holding b. The slice is not copied.
+func NewBlob(b []byte) Value {
+	if b == nil {
+		b = []byte{}
+	}
+	return Value{code: Blob, b: b}
+}
+
+// NewList returns a List Value holding elems.
+func NewList(elems ...Value) Value {
+	return Value{code: List, list: elems}
+}
+
+// NewDict returns a Dict Value holding entries, in order.
+func NewDict(entries ...Entry) Value {
+	return Value{code: Dict, dict: entries}
+}
+
+// Kind returns the type code of v: Nil, True, False, Integer, Float, Double,
+// String, Blob, List or Dict.
+func (v Value) Kind() Code {
+	if v.code == 0 {
+		return Nil
+	}
+	return v.code
+}
+
+func (v Value) mustBe(method string, codes ...Code) {
+	for _, c := range codes {
+		if v.Kind() == c {
+			return
+		}
+	}
+	panic("binpack: call of Value." + method + " on " + v.Kind().String() + " Value")
+}
+
+// Bool returns the value of a True or False Value.
+func (v Value) Bool() bool {
+	v.mustBe("Bool", True, False)
+	return v.code == True
+}
+
+// IntType returns the subtype of an Integer Value.
+func (v Value) IntType() Code {
+	v.mustBe("IntType", Integer)
+	return v.itype
+}
+
+// Int returns the value of an Integer. It panics if the value does not fit
+// an int64.
+func (v Value) Int() int64 {
+	v.mustBe("Int", Integer)
+	i, ok := header{code: Integer, tag: v.tag(), n: v.n}.int64()
+	if !ok {
+		panic("binpack: Value.Int of Integer overflowing int64")
+	}
+	return i
+}
+
+// Uint returns the value of a non-negative Integer.
+func (v Value) Uint() uint64 {
+	v.mustBe("Uint", Integer)
+	if v.neg {
+		panic("binpack: Value.Uint of negative Integer")
+	}
+	return v.n
+}
+
+// IsNegative reports whether an Integer is negative.
+func (v Value) IsNegative() bool {
+	v.mustBe("IsNegative", Integer)
+	return v.neg
+}
+
+// BigInt returns the value of an Integer, including negative values that
+// overflow an int64.
+func (v Value) BigInt() *big.Int {
+	v.mustBe("BigInt", Integer)
+	b := new(big.Int).SetUint64(v.n)
+	if v.neg {
+		b.Neg(b)
+	}
+	return b
+}
+
+// Float returns the value of a Float or Double.
+func (v Value) Float() float64 {
+	v.mustBe("Float", Float, Double)
+	if v.code == Float {
+		return float64(math.Float32frombits(uint32(v.n)))
+	}
+	return math.Float64frombits(v.n)
+}
+
+// Str returns the value of a String.
+func (v Value) Str() string {
+	v.mustBe("Str", String)
+	return v.s
+}
+
+// Bytes returns the value of a Blob.
+func (v Value) Bytes() []byte {
+	v.mustBe("Bytes", Blob)
+	return v.b
+}
+
+// Len returns the length of a String or Blob, or the number of elements of
+// a List or entries of a Dict.
+func (v Value) Len() int {
+	v.mustBe("Len", String, Blob, List, Dict)
+	switch v.code {
+	case String:
+		return len(v.s)
+	case Blob:
+		return len(v.b)
+	case List:
+		return len(v.list)
+	}
+	return len(v.dict)
+}
+
+// Index returns the i'th element of a List.
+func (v Value) Index(i int) Value {
+	v.mustBe("Index", List)
+	return v.list[i]
+}
+
+// Entry returns the i'th entry of a Dict.
+func (v Value) Entry(i int) Entry {
+	v.mustBe("Entry", Dict)
+	return v.dict[i]
+}
+
+// Key returns the value of the first entry of a Dict whose key is the
+// String k, and whether there is one.
+func (v Value) Key(k string) (Value, bool) {
+	v.mustBe("Key", Dict)
+	if i := v.keyIndex(k); i >= 0 {
+		return v.dict[i].Value, true
+	}
+	return Value{}, false
+}
+
+func (v Value) keyIndex(k string) int {
+	for i, e := range v.dict {
+		if e.Key.code == String && e.Key.s == k {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetIndex replaces the i'th element of a List.
+func (v *Value) SetIndex(i int, elem Value) {
+	v.mustBe("SetIndex", List)
+	v.list[i] = elem
+}
+
+// Append adds elems to the end of a List.
+func (v *Value) Append(elems ...Value) {
+	v.mustBe("Append", List)
+	v.list = append(v.list, elems...)
+}
+
+// SetKey sets the value of the String key k of a Dict. An existing entry is
+// updated in place, otherwise a new entry is added at the end.
+func (v *Value) SetKey(k string, val Value) {
+	v.mustBe("SetKey", Dict)
+	if i := v.keyIndex(k); i >= 0 {
+		v.dict[i].Value = val
+		return
+	}
+	v.dict = append(v.dict, Entry{Key: NewString(k), Value: val})
+}
+
+// DeleteKey removes the first entry of a Dict whose key is the String k and
+// reports whether there was one.
+func (v *Value) DeleteKey(k string) bool {
+	v.mustBe("DeleteKey", Dict)
+	i := v.keyIndex(k)
+	if i < 0 {
+		return false
+	}
+	v.dict = append(v.dict[:i:i], v.dict[i+1:]...)
+	return true
+}
+
+// Equal reports whether v and o are identical, that is whether they have
+// the same encoding. Dict entries are compared in order.
+func (v Value) Equal(o Value) bool {
+	if v.Kind() != o.Kind() {
+		return false
+	}
+	switch v.Kind() {
+	case Integer:
+		return v.itype == o.itype && v.neg == o.neg && v.n == o.n
+	case Float, Double:
+		return v.n == o.n
+	case String:
+		return v.s == o.s
+	case Blob:
+		return bytes.Equal(v.b, o.b)
+	case List:
+		if len(v.list) != len(o.list) {
+			return false
+		}
+		for i := range v.list {
+			if !v.list[i].Equal(o.list[i]) {
+				return false
+			}
+		}
+	case Dict:
+		if len(v.dict) != len(o.dict) {
+			return false
+		}
+		for i := range v.dict {
+			if !v.dict[i].Key.Equal(o.dict[i].Key) || !v.dict[i].Value.Equal(o.dict[i].Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// tag returns the last byte of the encoding of an Integer.
+func (v Value) tag() Code {
+	tag := Integer | v.itype
+	if v.neg {
+		tag |= IntegerNegative
+	}
+	return tag
+}
+
+// MarshalBinpack returns the encoding of v.
+func (v Value) MarshalBinpack() ([]byte, error) {
+	enc := new(Encoder)
+	enc.encodeTree(v)
+	return enc.buf.Bytes(), nil
+}
+
+// UnmarshalBinpack sets v to the value encoded in data, which must hold
+// exactly one value. Blobs are copied out of data.
+func (v *Value) UnmarshalBinpack(data []byte) (err error) {
+	defer catchError(&err)
+	d := decodeState{data: data}
+	t := d.tree()
+	if d.off < len(data) {
+		return syntaxErrorf(d.off, "trailing data after value")
+	}
+	*v = t
+	return nil
+}
+
+// encodeTree writes the encoding of v.
+func (enc *Encoder) encodeTree(v Value) {
+	switch v.Kind() {
+	case Integer:
+		enc.encodeInteger(v.tag(), v.n)
+	case Float:
+		enc.encodeFloat32(math.Float32frombits(uint32(v.n)))
+	case Double:
+		enc.encodeFloat64(math.Float64frombits(v.n))
+	case String:
+		enc.encodeString(v.s)
+	case Blob:
+		enc.encodeBlob(v.b)
+	case List:
+		enc.buf.WriteCode(List)
+		for _, e := range v.list {
+			enc.encodeTree(e)
+		}
+		enc.buf.WriteCode(Closure)
+	case Dict:
+		enc.buf.WriteCode(Dict)
+		for _, e := range v.dict {
+			enc.encodeTree(e.Key)
+			enc.encodeTree(e.Value)
+		}
+		enc.buf.WriteCode(Closure)
+	default:
+		enc.buf.WriteCode(v.Kind())
+	}
+}
+
+// tree decodes the next value into a Value.
+func (d *decodeState) tree() Value {
+	start := d.off
+	h := d.header()
+	switch h.code {
+	case Integer:
+		return Value{code: Integer, itype: h.intType(), neg: h.neg(), n: h.n}
+	case Float, Double:
+		b := d.payload(h)
+		if h.code == Float {
+			return Value{code: Float, n: uint64(binary.LittleEndian.Uint32(b))}
+		}
+		return Value{code: Double, n: binary.LittleEndian.Uint64(b)}
+	case String:
+		return NewString(string(d.payload(h)))
+	case Blob:
+		return NewBlob(append([]byte{}, d.payload(h)...))
+	case List:
+		d.enter(start)
+		v := Value{code: List, list: []Value{}}
+		for !d.closure() {
+			v.list = append(v.list, d.tree())
+		}
+		d.leave()
+		return v
+	case Dict:
+		d.enter(start)
+		v := Value{code: Dict, dict: []Entry{}}
+		for !d.closure() {
+			k := d.tree()
+			v.dict = append(v.dict, Entry{Key: k, Value: d.tree()})
+		}
+		d.leave()
+		return v
+	case Closure:
+		error_(syntaxErrorf(start, "unexpected Closure"))
+	}
+	return Value{code: h.code}
+}
@@ -0,0 +1,203 @@
+package binpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestValue_RoundTrip(t *testing.T) {
+	testCases := []string{
+		"0f", "04", "05", "69", "59", "8848", "60",
+		"ffffffffffffffffff40", "ffffffffffffffffff41", "ffffffffffffffffff60",
+		"07c3f54840", "061f85eb51b81e0940", "0700000080",
+		"20", "2568656c6c6f", "10", "15616263c2a2",
+		"0201", "0221612162216301", "0202404001024040010240400101",
+		"0301", "034126737472696e6701", "03216102410f0101",
+	}
+	for _, in := range testCases {
+		data, _ := hex.DecodeString(in)
+		var v Value
+		if err := v.UnmarshalBinpack(data); err != nil {
+			t.Fatalf("Value:UnmarshalBinpack(%s) error %v", in, err)
+		}
+		out, err := v.MarshalBinpack()
+		if err != nil {
+			t.Fatalf("Value:MarshalBinpack error %v", err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("Value:MarshalBinpack got %x; wanted %s", out, in)
+		}
+	}
+
+	// Headers with needless high continuation bits come back in their
+	// shortest form.
+	nonCanonical := []struct{ in, want string }{
+		{"8060", "60"},
+		{"8140", "41"},
+		{"812061", "2161"},
+		{"8010", "10"},
+	}
+	for _, test := range nonCanonical {
+		data, _ := hex.DecodeString(test.in)
+		var v Value
+		if err := v.UnmarshalBinpack(data); err != nil {
+			t.Fatalf("Value:UnmarshalBinpack(%s) error %v", test.in, err)
+		}
+		out, _ := v.MarshalBinpack()
+		if got := hex.EncodeToString(out); got != test.want {
+			t.Fatalf("Value:MarshalBinpack of %s got %s; wanted %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestValue_Constructors(t *testing.T) {
+	testCases := []struct {
+		in   Value
+		want interface{}
+	}{
+		{Value{}, nil},
+		{NewNil(), nil},
+		{NewBool(true), true},
+		{NewInt(-1, IntegerTypeByte), int8(-1)},
+		{NewInt(1, IntegerTypeInt), int32(1)},
+		{NewInt(math.MinInt64, IntegerTypeLong), int64(math.MinInt64)},
+		{NewUint(math.MaxUint64, IntegerTypeLong), uint64(math.MaxUint64)},
+		{NewFloat(3.14), float32(3.14)},
+		{NewDouble(3.14), 3.14},
+		{NewString("hello"), "hello"},
+		{NewBlob(nil), []byte{}},
+		{NewList(NewString("a"), NewString("b")), []string{"a", "b"}},
+		{NewDict(Entry{NewInt(1, IntegerTypeLong), NewString("string")}), map[int]string{1: "string"}},
+	}
+	for _, test := range testCases {
+		got, err := Marshal(test.in)
+		if err != nil {
+			t.Fatalf("binpack:Marshal error %v", err)
+		}
+		want, _ := Marshal(test.want)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("binpack:Marshal(%#v) got %x; wanted %x", test.want, got, want)
+		}
+	}
+}
+
+func TestValue_Accessors(t *testing.T) {
+	data, _ := Marshal(map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{"name": "ann", "age": int16(-30), "avatar": []byte{1, 2}},
+		},
+		"ratio": float32(0.5),
+	})
+	var v Value
+	if err := Unmarshal(data, &v); err != nil {
+		t.Fatalf("binpack:Unmarshal error %v", err)
+	}
+	if v.Kind() != Dict || v.Len() != 2 {
+		t.Fatalf("Value:Kind got %v with %v entries", v.Kind(), v.Len())
+	}
+	users, ok := v.Key("users")
+	if !ok || users.Kind() != List || users.Len() != 1 {
+		t.Fatalf("Value:Key(users) got %v, %v", users.Kind(), ok)
+	}
+	user := users.Index(0)
+	name, _ := user.Key("name")
+	age, _ := user.Key("age")
+	avatar, _ := user.Key("avatar")
+	if name.Str() != "ann" || age.Int() != -30 || age.IntType() != IntegerTypeShort || !age.IsNegative() {
+		t.Fatalf("Value accessors got %q, %v (%v)", name.Str(), age.Int(), age.IntType())
+	}
+	if avatar.Kind() != Blob || !bytes.Equal(avatar.Bytes(), []byte{1, 2}) {
+		t.Fatalf("Value:Bytes got %v", avatar.Bytes())
+	}
+	ratio, _ := v.Key("ratio")
+	if ratio.Kind() != Float || ratio.Float() != 0.5 {
+		t.Fatalf("Value:Float got %v (%v)", ratio.Float(), ratio.Kind())
+	}
+	if _, ok := v.Key("missing"); ok {
+		t.Fatal("Value:Key(missing) found an entry")
+	}
+
+	user.SetKey("name", NewString("bob"))
+	user.SetKey("admin", NewBool(true))
+	if !user.DeleteKey("avatar") || user.DeleteKey("avatar") {
+		t.Fatal("Value:DeleteKey did not delete exactly once")
+	}
+	users.SetIndex(0, user)
+	users.Append(NewNil())
+	if users.Len() != 2 || users.Index(1).Kind() != Nil || users.Index(0).Len() != 3 {
+		t.Fatalf("Value:Append and SetIndex got %v elements", users.Len())
+	}
+	if age, _ := user.Key("age"); !age.Equal(NewInt(-30, IntegerTypeShort)) {
+		t.Fatal("Value:Equal reports different integers")
+	}
+	if last := user.Entry(2); last.Key.Str() != "admin" || !last.Value.Equal(NewBool(true)) {
+		t.Fatal("Value:SetKey did not append the new entry")
+	}
+	want := NewList(NewDict(Entry{NewString("k"), NewDouble(1)}), NewNil())
+	got := NewList(NewDict(Entry{NewString("k"), NewDouble(1)}))
+	got.Append(NewNil())
+	if !got.Equal(want) || got.Equal(users) {
+		t.Fatal("Value:Equal compared Lists wrongly")
+	}
+	if NewFloat(1).Equal(NewDouble(1)) || NewInt(1, IntegerTypeByte).Equal(NewInt(1, IntegerTypeLong)) {
+		t.Fatal("Value:Equal ignores wire types")
+	}
+}
+
+func TestValue_BigInt(t *testing.T) {
+	testCases := []string{"0", "-1", "9223372036854775808", "-9223372036854775809", "18446744073709551615", "-18446744073709551615"}
+	for _, in := range testCases {
+		b, _ := new(big.Int).SetString(in, 10)
+		v := NewBigInt(b, IntegerTypeLong)
+		if got := v.String(); got != in {
+			t.Fatalf("binpack:NewBigInt(%s) got %s", in, got)
+		}
+		if got := v.BigInt(); got.Cmp(b) != 0 {
+			t.Fatalf("Value:BigInt of %s got %v", in, got)
+		}
+	}
+	if got := NewInt(-5, IntegerTypeByte).BigInt(); got.Int64() != -5 {
+		t.Fatalf("Value:BigInt of -5i8 got %v", got)
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("binpack:NewBigInt should have panicked on 2^64")
+		}
+	}()
+	NewBigInt(new(big.Int).Lsh(big.NewInt(1), 64), IntegerTypeLong)
+}
+
+func TestValue_Panics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("Value:Int should have panicked on a String")
+		}
+	}()
+	NewString("1").Int()
+}
+
+func TestValue_InGoTypes(t *testing.T) {
+	in := map[string]Value{"f": NewFloat(1), "l": NewList(NewInt(5, IntegerTypeByte))}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("binpack:Marshal error %v", err)
+	}
+	var out map[string]Value
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("binpack:Unmarshal error %v", err)
+	}
+	if !out["f"].Equal(in["f"]) || !out["l"].Equal(in["l"]) {
+		t.Fatalf("binpack:Unmarshal into map[string]Value lost information")
+	}
+
+	var p []*Value
+	if err := Unmarshal([]byte{0x02, 0x0f, 0x41, 0x01}, &p); err != nil {
+		t.Fatalf("binpack:Unmarshal error %v", err)
+	}
+	if len(p) != 2 || p[0] != nil || p[1].Int() != 1 {
+		t.Fatalf("binpack:Unmarshal into []*Value got %v", p)
+	}
+}
@@ -0,0 +1,261 @@
+package binpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+)
+
+// ErrNotFound is returned when a List index or Dict key being looked up
+// does not exist.
+var ErrNotFound = errors.New("binpack: value not found")
+
+// A Node is a read-only view of one encoded value. Nothing is decoded until
+// it is asked for: the first time the children of a List or Dict are needed,
+// their offsets are located by skipping over the encoded bytes and cached,
+// so that further lookups only decode the values they return.
+//
+// Lookups never fail outright. Looking up something that does not exist
+// returns a Node whose Err method reports why, and whose accessors return
+// zero values, so lookups can be chained:
+//
+//	name := binpack.View(data).Index(1000).Key("name").String()
+//
+// A Node is safe for concurrent use by multiple goroutines.
+type Node struct {
+	data []byte // the encoding of the value
+	h    header
+	err  error
+
+	once sync.Once
+	offs []int // offsets of the children in data, followed by the offset of the Closure
+	ierr error // error found while indexing the children
+}
+
+// View returns a Node for the first value encoded in data. The Node refers
+// to data, which must not be modified while it is in use.
+func View(data []byte) *Node {
+	n, err := Next(data)
+	if err != nil {
+		return &Node{err: err}
+	}
+	return newNode(data[0:n])
+}
+
+func newNode(data []byte) *Node {
+	h, err := readHeader(data, 0)
+	return &Node{data: data, h: h, err: err}
+}
+
+// Err returns the error that made the Node invalid, or nil for a valid Node.
+func (n *Node) Err() error {
+	return n.err
+}
+
+// Exists reports whether the Node is valid.
+func (n *Node) Exists() bool {
+	return n.err == nil
+}
+
+// Kind returns the type code of the value, or Nil for an invalid Node.
+func (n *Node) Kind() Code {
+	if n.err != nil {
+		return Nil
+	}
+	return n.h.code
+}
+
+// Raw returns the encoding of the value.
+func (n *Node) Raw() []byte {
+	return n.data
+}
+
+// Value decodes the value into a Value.
+func (n *Node) Value() (Value, error) {
+	var v Value
+	if n.err != nil {
+		return v, n.err
+	}
+	err := v.UnmarshalBinpack(n.data)
+	return v, err
+}
+
+// Len returns the length of a String or Blob, or the number of elements of
+// a List or entries of a Dict. It returns 0 for other values.
+func (n *Node) Len() int {
+	switch n.Kind() {
+	case String, Blob:
+		return int(n.h.n)
+	case List, Dict:
+		offs := n.children()
+		if n.ierr != nil {
+			return 0
+		}
+		if n.h.code == Dict {
+			return (len(offs) - 1) / 2
+		}
+		return len(offs) - 1
+	}
+	return 0
+}
+
+// Index returns the i'th element of a List.
+func (n *Node) Index(i int) *Node {
+	if n.err != nil {
+		return n
+	}
+	if n.h.code != List {
+		return &Node{err: errors.New("binpack: Index of " + n.h.code.String())}
+	}
+	offs := n.children()
+	if n.ierr != nil {
+		return &Node{err: n.ierr}
+	}
+	if i < 0 || i >= len(offs)-1 {
+		return &Node{err: ErrNotFound}
+	}
+	return n.child(i)
+}
+
+// Key returns the value of the first entry of a Dict whose key is the
+// String k.
+func (n *Node) Key(k string) *Node {
+	if n.err != nil {
+		return n
+	}
+	if n.h.code != Dict {
+		return &Node{err: errors.New("binpack: Key of " + n.h.code.String())}
+	}
+	offs := n.children()
+	if n.ierr != nil {
+		return &Node{err: n.ierr}
+	}
+	for i := 0; i+1 < len(offs)-1; i += 2 {
+		if keyEquals(n.data[offs[i]:offs[i+1]], k) {
+			return n.child(i + 1)
+		}
+	}
+	return &Node{err: ErrNotFound}
+}
+
+// Entry returns the key and value of the i'th entry of a Dict.
+func (n *Node) Entry(i int) (key, value *Node) {
+	if n.err != nil {
+		return n, n
+	}
+	if n.h.code != Dict {
+		err := &Node{err: errors.New("binpack: Entry of " + n.h.code.String())}
+		return err, err
+	}
+	offs := n.children()
+	if n.ierr != nil {
+		err := &Node{err: n.ierr}
+		return err, err
+	}
+	if i < 0 || 2*i+1 >= len(offs)-1 {
+		err := &Node{err: ErrNotFound}
+		return err, err
+	}
+	return n.child(2 * i), n.child(2*i + 1)
+}
+
+// keyEquals reports whether the encoded value b is the String k.
+func keyEquals(b []byte, k string) bool {
+	h, err := readHeader(b, 0)
+	return err == nil && h.code == String && h.n == uint64(len(k)) &&
+		string(b[h.size:]) == k
+}
+
+// children returns the offsets of the children of a List or Dict, locating
+// them on first use.
+func (n *Node) children() []int {
+	n.once.Do(func() {
+		off := n.h.size
+		offs := []int{}
+		for off < len(n.data) && Code(n.data[off]) != Closure {
+			offs = append(offs, off)
+			end, err := skipValue(n.data, off)
+			if err != nil {
+				n.ierr = err
+				return
+			}
+			off = end
+		}
+		if n.h.code == Dict && len(offs)%2 != 0 {
+			n.ierr = syntaxErrorf(off, "Dict with a key but no value")
+			return
+		}
+		n.offs = append(offs, off)
+	})
+	return n.offs
+}
+
+func (n *Node) child(i int) *Node {
+	return newNode(n.data[n.offs[i]:n.offs[i+1]])
+}
+
+// payload returns the bytes following the header.
+func (n *Node) payload() []byte {
+	return n.data[n.h.size:]
+}
+
+// Bool returns the value of True or False, or false for other values.
+func (n *Node) Bool() bool {
+	return n.Kind() == True
+}
+
+// Int returns the value of an Integer, or 0 for other values or Integers
+// that do not fit an int64.
+func (n *Node) Int() int64 {
+	if n.Kind() != Integer {
+		return 0
+	}
+	i, _ := n.h.int64()
+	return i
+}
+
+// Uint returns the value of a non-negative Integer, or 0 for other values.
+func (n *Node) Uint() uint64 {
+	if n.Kind() != Integer || n.h.neg() {
+		return 0
+	}
+	return n.h.n
+}
+
+// Float returns the value of a Float, Double or Integer, or 0 for other
+// values.
+func (n *Node) Float() float64 {
+	switch n.Kind() {
+	case Float:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(n.payload())))
+	case Double:
+		return math.Float64frombits(binary.LittleEndian.Uint64(n.payload()))
+	case Integer:
+		if n.h.neg() {
+			return -float64(n.h.n)
+		}
+		return float64(n.h.n)
+	}
+	return 0
+}
+
+// String returns the contents of a String or Blob, or "" for other values.
+func (n *Node) String() string {
+	switch n.Kind() {
+	case String, Blob:
+		return string(n.payload())
+	}
+	return ""
+}
+
+// Bytes returns the contents of a Blob or String, or nil for other values.
+// The returned slice refers to the viewed data.
+func (n *Node) Bytes() []byte {
+	switch n.Kind() {
+	case String, Blob:
+		p := n.payload()
+		return p[:len(p):len(p)]
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package binpack
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	type user map[string]interface{}
+	users := make([]user, 2000)
+	for i := range users {
+		users[i] = user{"name": fmt.Sprintf("user%d", i), "id": i, "avatar": []byte{byte(i)}}
+	}
+	data, err := Marshal(map[string]interface{}{"users": users, "ratio": float32(0.25)})
+	if err != nil {
+		t.Fatalf("binpack:Marshal error %v", err)
+	}
+
+	view := View(data)
+	if view.Kind() != Dict || view.Len() != 2 {
+		t.Fatalf("Node:Kind got %v with %v entries", view.Kind(), view.Len())
+	}
+	list := view.Key("users")
+	if list.Len() != 2000 {
+		t.Fatalf("Node:Len got %v; wanted %v", list.Len(), 2000)
+	}
+	u := list.Index(1000)
+	if got := u.Key("name").String(); got != "user1000" {
+		t.Fatalf("Node:String got %q; wanted %q", got, "user1000")
+	}
+	if got := u.Key("id").Int(); got != 1000 {
+		t.Fatalf("Node:Int got %v; wanted %v", got, 1000)
+	}
+	if got := u.Key("avatar").Bytes(); len(got) != 1 || got[0] != byte(1000%256) {
+		t.Fatalf("Node:Bytes got %v", got)
+	}
+	if got := view.Key("ratio").Float(); got != 0.25 {
+		t.Fatalf("Node:Float got %v; wanted %v", got, 0.25)
+	}
+	k, v := view.Entry(0)
+	if k.Kind() != String || !v.Exists() {
+		t.Fatalf("Node:Entry got %v, %v", k.Kind(), v.Err())
+	}
+	val, err := u.Value()
+	if err != nil {
+		t.Fatalf("Node:Value error %v", err)
+	}
+	if name, _ := val.Key("name"); name.Str() != "user1000" {
+		t.Fatalf("Node:Value got %q", name.Str())
+	}
+}
+
+func TestView_Missing(t *testing.T) {
+	data, _ := Marshal([]interface{}{map[string]int{"a": 1}, "x"})
+	view := View(data)
+	testCases := []struct {
+		node     *Node
+		notFound bool
+	}{
+		{view.Index(2), true},
+		{view.Index(-1), true},
+		{view.Index(0).Key("b"), true},
+		{view.Index(5).Key("a").Index(1), true},
+		{view.Key("a"), false},
+		{view.Index(1).Index(0), false},
+		{View([]byte{0x02, 0x41}), false},
+	}
+	for i, test := range testCases {
+		if test.node.Exists() {
+			t.Fatalf("case %v: Node should not exist", i)
+		}
+		if (test.node.Err() == ErrNotFound) != test.notFound {
+			t.Fatalf("case %v: Node:Err got %v", i, test.node.Err())
+		}
+		if test.node.Int() != 0 || test.node.String() != "" || test.node.Len() != 0 {
+			t.Fatalf("case %v: invalid Node returned a non-zero value", i)
+		}
+	}
+	if View([]byte{0x03, 0x41, 0x01}).Key("a").Err() == nil {
+		t.Fatal("Node:Key on a Dict with a dangling key should fail")
+	}
+}
+
+func TestView_Concurrent(t *testing.T) {
+	data, _ := Marshal([]int{0, 1, 2, 3, 4, 5, 6, 7})
+	view := View(data)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if got := view.Index(i).Int(); got != int64(i) {
+				t.Errorf("Node:Index(%v) got %v", i, got)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,146 @@
+package binpack
+
+import (
+	"errors"
+	"math/big"
+)
+
+// A Visitor is called by Walk for every value of a document, in encoding
+// order. Each callback receives the path of the value it is called for.
+// The path is reused between calls and must be copied to be retained.
+//
+// Returning SkipValue from OnListStart, OnDictStart or OnDictKey skips the
+// List, the Dict or the value of the entry, including the matching
+// OnClosure call. Returning StopWalk ends the walk without error. Any other
+// error ends the walk and is returned by Walk.
+type Visitor interface {
+	// OnListStart is called at the start of a List.
+	OnListStart(path Path) error
+	// OnDictStart is called at the start of a Dict.
+	OnDictStart(path Path) error
+	// OnDictKey is called with the key of each entry of a Dict, before its
+	// value is visited. The path is the one of the value.
+	OnDictKey(path Path, key Value) error
+	// OnScalar is called for every value that is not a List or Dict. The
+	// code is Nil, True, False, Float, Double, String, Blob, or Integer
+	// combined with the integer subtype, as in Integer|IntegerTypeByte.
+	// The value holds what Unmarshal would store in an interface{}, except
+	// that a Blob refers to the walked data instead of being copied and a
+	// negative Integer below math.MinInt64 is a *big.Int.
+	OnScalar(path Path, code Code, value interface{}) error
+	// OnClosure is called at the end of a List or Dict, with its code.
+	OnClosure(path Path, code Code) error
+}
+
+var (
+	// SkipValue is returned by a Visitor to skip a value.
+	SkipValue = errors.New("binpack: skip this value")
+	// StopWalk is returned by a Visitor to end the walk.
+	StopWalk = errors.New("binpack: stop walk")
+)
+
+// BaseVisitor implements every Visitor callback by doing nothing. Embed it
+// in a Visitor to only implement the callbacks of interest.
+type BaseVisitor struct{}
+
+func (BaseVisitor) OnListStart(path Path) error                            { return nil }
+func (BaseVisitor) OnDictStart(path Path) error                            { return nil }
+func (BaseVisitor) OnDictKey(path Path, key Value) error                   { return nil }
+func (BaseVisitor) OnScalar(path Path, code Code, value interface{}) error { return nil }
+func (BaseVisitor) OnClosure(path Path, code Code) error                   { return nil }
+
+// Walk calls the methods of v for every value encoded in data, which can
+// hold several concatenated documents, each walked from the root path.
+// Malformed data ends the walk with a *SyntaxError, or io.ErrUnexpectedEOF
+// if data ends inside a value, after v has seen the values before.
+func Walk(data []byte, v Visitor) (err error) {
+	defer catchError(&err)
+	w := walker{d: decodeState{data: data, opts: DecodeOptions{AliasBlobs: true}}, v: v}
+	for w.d.off < len(data) {
+		if err := w.value(); err != nil {
+			if err == StopWalk {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// walker holds the state of Walk.
+type walker struct {
+	d    decodeState
+	v    Visitor
+	path Path
+}
+
+// value walks the next value, returning the error of a callback.
+func (w *walker) value() error {
+	start := w.d.off
+	h := w.d.header()
+	switch h.code {
+	case List:
+		if err := w.v.OnListStart(w.path); err != nil {
+			return w.skip(err, start)
+		}
+		w.d.enter(start)
+		for i := 0; !w.d.closure(); i++ {
+			w.path = append(w.path, i)
+			err := w.value()
+			w.path = w.path[:len(w.path)-1]
+			if err != nil {
+				return err
+			}
+		}
+		w.d.leave()
+		return ignoreSkip(w.v.OnClosure(w.path, List))
+	case Dict:
+		if err := w.v.OnDictStart(w.path); err != nil {
+			return w.skip(err, start)
+		}
+		w.d.enter(start)
+		for !w.d.closure() {
+			k := w.d.tree()
+			w.path = append(w.path, pathElem(k))
+			err := w.v.OnDictKey(w.path, k)
+			if err == nil {
+				err = w.value()
+			} else {
+				err = w.skip(err, w.d.off)
+			}
+			w.path = w.path[:len(w.path)-1]
+			if err != nil {
+				return err
+			}
+		}
+		w.d.leave()
+		return ignoreSkip(w.v.OnClosure(w.path, Dict))
+	case Integer:
+		var v interface{}
+		if _, ok := h.int64(); h.neg() && !ok {
+			v = new(big.Int).Neg(new(big.Int).SetUint64(h.n))
+		} else {
+			v = w.d.interfaceValue(h, start)
+		}
+		return ignoreSkip(w.v.OnScalar(w.path, Integer|h.intType(), v))
+	}
+	return ignoreSkip(w.v.OnScalar(w.path, h.code, w.d.interfaceValue(h, start)))
+}
+
+// skip handles the error returned by the callback of the value starting at
+// start: SkipValue moves past the value and any other error is returned.
+func (w *walker) skip(err error, start int) error {
+	if err != SkipValue {
+		return err
+	}
+	w.d.off = start
+	w.d.skip()
+	return nil
+}
+
+func ignoreSkip(err error) error {
+	if err == SkipValue {
+		return nil
+	}
+	return err
+}
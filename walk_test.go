@@ -0,0 +1,163 @@
+package binpack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// traceVisitor records the calls it gets, and returns the error set for a
+// call from it.
+type traceVisitor struct {
+	calls []string
+	ret   map[string]error
+}
+
+func (t *traceVisitor) record(call string) error {
+	t.calls = append(t.calls, call)
+	return t.ret[call]
+}
+
+func (t *traceVisitor) OnListStart(path Path) error {
+	return t.record("list " + path.String())
+}
+
+func (t *traceVisitor) OnDictStart(path Path) error {
+	return t.record("dict " + path.String())
+}
+
+func (t *traceVisitor) OnDictKey(path Path, key Value) error {
+	return t.record("key " + path.String() + " " + key.String())
+}
+
+func (t *traceVisitor) OnScalar(path Path, code Code, value interface{}) error {
+	return t.record(fmt.Sprintf("scalar %s %#02x %#v", path, byte(code), value))
+}
+
+func (t *traceVisitor) OnClosure(path Path, code Code) error {
+	return t.record("closure " + path.String() + " " + code.String())
+}
+
+func TestWalk(t *testing.T) {
+	data, _ := Parse(`{"a": [1i8, -2, "x"], 3: {"b": h'01'}, "c": nil} true`)
+	all := []string{
+		`dict .`,
+		`key .a "a"`,
+		`list .a`,
+		`scalar .a[0] 0x48 1`,
+		`scalar .a[1] 0x40 -2`,
+		`scalar .a[2] 0x20 "x"`,
+		`closure .a List`,
+		`key {3} 3`,
+		`dict {3}`,
+		`key {3}.b "b"`,
+		`scalar {3}.b 0x10 []byte{0x1}`,
+		`closure {3} Dict`,
+		`key .c "c"`,
+		`scalar .c 0x0f <nil>`,
+		`closure . Dict`,
+		`scalar . 0x04 true`,
+	}
+	v := &traceVisitor{}
+	if err := Walk(data, v); err != nil || !reflect.DeepEqual(v.calls, all) {
+		t.Fatalf("binpack:Walk got %q, %v; wanted %q", v.calls, err, all)
+	}
+
+	testCases := []struct {
+		ret  map[string]error
+		want []string
+	}{
+		{map[string]error{`list .a`: SkipValue}, append(all[:3:3], all[7:]...)},
+		{map[string]error{`key {3} 3`: SkipValue}, append(all[:8:8], all[12:]...)},
+		{map[string]error{`dict {3}`: SkipValue}, append(all[:9:9], all[12:]...)},
+		{map[string]error{`scalar .a[0] 0x48 1`: SkipValue, `closure .a List`: SkipValue}, all},
+		{map[string]error{`scalar .a[2] 0x20 "x"`: StopWalk}, all[:6]},
+		{map[string]error{`dict .`: SkipValue}, []string{all[0], all[15]}},
+	}
+	for _, test := range testCases {
+		v := &traceVisitor{ret: test.ret}
+		if err := Walk(data, v); err != nil || !reflect.DeepEqual(v.calls, test.want) {
+			t.Fatalf("binpack:Walk with %v got %q, %v; wanted %q", test.ret, v.calls, err, test.want)
+		}
+	}
+
+	fail := errors.New("fail")
+	v = &traceVisitor{ret: map[string]error{`key .c "c"`: fail}}
+	if err := Walk(data, v); err != fail || len(v.calls) != 13 {
+		t.Fatalf("binpack:Walk got %q, %v; wanted to stop with %v", v.calls, err, fail)
+	}
+}
+
+func TestWalk_Malformed(t *testing.T) {
+	testCases := []struct {
+		in  []byte
+		err error
+	}{
+		{[]byte{0x02, 0x41}, io.ErrUnexpectedEOF},
+		{[]byte{0x03, 0x41, 0x01}, &SyntaxError{}},
+		{[]byte{0x01}, &SyntaxError{}},
+		{[]byte{0x02, 0x00, 0x01}, &SyntaxError{}},
+		{bytes.Repeat([]byte{0x02}, 1<<20), &SyntaxError{}},
+	}
+	for _, test := range testCases {
+		err := Walk(test.in, BaseVisitor{})
+		if reflect.TypeOf(err) != reflect.TypeOf(test.err) || err == nil {
+			t.Fatalf("binpack:Walk(%.20x) got error %v; wanted %T", test.in, err, test.err)
+		}
+	}
+}
+
+type scalars struct {
+	BaseVisitor
+	values []interface{}
+}
+
+func (s *scalars) OnScalar(path Path, code Code, value interface{}) error {
+	s.values = append(s.values, value)
+	return nil
+}
+
+func TestWalk_Integers(t *testing.T) {
+	data, err := Parse("-9223372036854775808 -9223372036854775809 -18446744073709551615 18446744073709551615")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &scalars{}
+	if err := Walk(data, v); err != nil {
+		t.Fatalf("binpack:Walk error %v", err)
+	}
+	want := []interface{}{
+		int64(math.MinInt64),
+		new(big.Int).Sub(big.NewInt(math.MinInt64), big.NewInt(1)),
+		new(big.Int).Neg(new(big.Int).SetUint64(math.MaxUint64)),
+		uint64(math.MaxUint64),
+	}
+	if !reflect.DeepEqual(v.values, want) {
+		t.Fatalf("binpack:Walk got %v; wanted %v", v.values, want)
+	}
+}
+
+type blobSizes struct {
+	BaseVisitor
+	total int
+}
+
+func (b *blobSizes) OnScalar(path Path, code Code, value interface{}) error {
+	if code == Blob {
+		b.total += len(value.([]byte))
+	}
+	return nil
+}
+
+func TestBaseVisitor(t *testing.T) {
+	data, _ := Marshal(map[string][][]byte{"a": {{1, 2}, {3}}, "b": nil})
+	v := &blobSizes{}
+	if err := Walk(data, v); err != nil || v.total != 3 {
+		t.Fatalf("binpack:Walk got total %d, %v; wanted 3", v.total, err)
+	}
+}